@@ -0,0 +1,292 @@
+package mybase
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// bindTag is the struct tag key used by Config.Bind and
+// Command.AddOptionsFromStruct.
+const bindTag = "mybase"
+
+var (
+	durationType = reflect.TypeFor[time.Duration]()
+	regexpType   = reflect.TypeFor[*regexp.Regexp]()
+)
+
+// Bind populates the exported fields of the struct pointed to by dst, using
+// option values from cfg. Fields are matched to options using struct tags, for
+// example `mybase:"concurrency"`. Any comma-separated flags following the
+// option name in the tag are ignored; these are only used by
+// Command.AddOptionsFromStruct. Each field's value is obtained using the
+// typed getter corresponding to the field's type:
+//
+//   - string: Get
+//   - bool: GetBool
+//   - int: GetInt, or GetCount for options created by CountOption
+//   - uint64: GetBytes
+//   - float64: GetFloat
+//   - time.Duration: GetDuration
+//   - *regexp.Regexp: GetRegexp
+//   - []string: GetAll for options marked as Repeatable, or otherwise GetSlice
+//     with a comma delimiter and unwrapFullValue set to true; GetSlice is also
+//     used for repeatable options which have no value other than their default
+//   - map[string]string: GetMap with delimiters ',' and '='
+//
+// Fields without a tag, or with a tag of "-", are ignored, unless the field is
+// a struct (or embedded struct) without a tag, in which case its fields are
+// bound recursively. This permits options to be organized into nested structs,
+// for example one per option group.
+//
+// If any values cannot be parsed, Bind continues with the remaining fields, and
+// then returns all errors joined together via errors.Join. Each of these
+// errors is an OptionValueError, and the corresponding fields are left
+// unchanged. Bind panics if dst is not a non-nil pointer to a struct, or if a
+// tag refers to an option which does not exist or is on a field of an
+// unsupported type, since these are indicative of programmer error.
+//
+// Bind is typically called at the start of a CommandHandler, in place of many
+// individual calls to getters.
+func (cfg *Config) Bind(dst any) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		panic(fmt.Errorf("Assertion failed: Bind requires a non-nil pointer to a struct, instead found %T", dst))
+	}
+	return errors.Join(cfg.bindStruct(v.Elem())...)
+}
+
+// bindStruct populates the tagged fields of struct value v, recursing into
+// untagged struct fields. It returns a slice of any errors encountered.
+func (cfg *Config) bindStruct(v reflect.Value) (errs []error) {
+	cache := cfg.current()
+	forEachTaggedField(v, func(field reflect.StructField, value reflect.Value, tag optionTag) {
+		if _, ok := cache.unifiedValues[tag.name]; !ok {
+			panic(fmt.Errorf("Assertion failed: field %s is bound to unknown option %s", field.Name, tag.name))
+		}
+		if err := cfg.bindField(value, tag.name); err != nil {
+			errs = append(errs, err)
+		}
+	})
+	return errs
+}
+
+// optionTag represents a parsed struct tag used by Config.Bind and
+// Command.AddOptionsFromStruct: an option name, optionally followed by
+// comma-separated flags.
+type optionTag struct {
+	name  string
+	flags []string
+}
+
+func (tag optionTag) has(flag string) bool {
+	return slices.Contains(tag.flags, flag)
+}
+
+// forEachTaggedField calls fn for each exported field of struct value v which
+// has a bindTag struct tag, other than "-". Untagged struct fields, including
+// embedded structs, are traversed recursively. Panics if a tagged field is
+// unexported.
+func forEachTaggedField(v reflect.Value, fn func(field reflect.StructField, value reflect.Value, tag optionTag)) {
+	t := v.Type()
+	for n := range t.NumField() {
+		field := t.Field(n)
+		raw, tagged := field.Tag.Lookup(bindTag)
+		if !tagged {
+			if field.Type.Kind() == reflect.Struct && field.IsExported() {
+				forEachTaggedField(v.Field(n), fn)
+			}
+			continue
+		} else if raw == "-" {
+			continue
+		} else if !field.IsExported() {
+			panic(fmt.Errorf("Assertion failed: unexported field %s.%s cannot have a %s tag", t.Name(), field.Name, bindTag))
+		}
+		name, flags, _ := strings.Cut(raw, ",")
+		tag := optionTag{name: name}
+		if flags != "" {
+			tag.flags = strings.Split(flags, ",")
+		}
+		fn(field, v.Field(n), tag)
+	}
+}
+
+// bindField sets the value of field to the value of the named option, using
+// the getter corresponding to the field's type.
+func (cfg *Config) bindField(field reflect.Value, name string) error {
+	var value any
+	var err error
+	opt := cfg.FindOption(name)
+	switch field.Type() {
+	case durationType:
+		value, err = cfg.GetDuration(name)
+	case regexpType:
+		value, err = cfg.GetRegexp(name)
+	default:
+		switch field.Kind() {
+		case reflect.String:
+			value = cfg.Get(name)
+		case reflect.Bool:
+			value = cfg.GetBool(name)
+		case reflect.Int:
+			if opt != nil && opt.ValueType == ValueTypeCount {
+				value = cfg.GetCount(name)
+			} else {
+				value, err = cfg.GetInt(name)
+			}
+		case reflect.Uint64:
+			value, err = cfg.GetBytes(name)
+		case reflect.Float64:
+			value, err = cfg.GetFloat(name)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				break
+			} else if opt != nil && opt.repeatable && cfg.Source(name) != cfg.CLI.Command {
+				value = cfg.GetAll(name)
+			} else {
+				// This includes the default value of repeatable options, which is a
+				// single comma-separated string, as per AddOptionsFromStruct
+				value = cfg.GetSlice(name, ',', true)
+			}
+		case reflect.Map:
+			if field.Type().Key().Kind() != reflect.String || field.Type().Elem().Kind() != reflect.String {
+				break
+			}
+			value, err = cfg.GetMap(name, ',', '=')
+		}
+	}
+	if err != nil {
+		return err
+	} else if value == nil {
+		panic(fmt.Errorf("Assertion failed: option %s is bound to a field of unsupported type %s", name, field.Type()))
+	}
+	field.Set(reflect.ValueOf(value).Convert(field.Type()))
+	return nil
+}
+
+// AddOptionsFromStruct adds an Option to cmd for each tagged field of v, which
+// must be a struct or pointer to a struct, setting the Group of each option to
+// the supplied string. This permits a single struct type to serve as both the
+// declaration of a command's options, and the destination for Config.Bind.
+// Fields are traversed using the same rules as Bind, and the type of each
+// Option is determined by the field's type: for example, a bool field results
+// in a BoolOption, an int field in an IntOption, a uint64 field in a
+// BytesOption, a time.Duration field in a DurationOption, and a
+// map[string]string field in a MapOption. A []string field results in a
+// StringOption with a comma-separated default value.
+//
+// Each option's default value is taken from the field's current value in v.
+// Other aspects of the Option may be controlled using struct tags:
+//
+//   - `mybase:"name,flags"`: option name, optionally followed by
+//     comma-separated flags: "hidden" (see Option.Hidden), "optional" (see
+//     Option.ValueOptional), "repeatable" (see Option.Repeatable), or "count"
+//     (int fields only; creates a CountOption instead of an IntOption)
+//   - `short:"c"`: single-character shorthand
+//   - `desc:"text"`: description, for display in help output
+//   - `deprecated:"details"`: marks the option as deprecated, as per
+//     Option.MarkDeprecated; the details may be empty
+//
+// Panics if v is not a struct or pointer to a struct, or if any tag is invalid
+// or is on a field of an unsupported type, since these are indicative of
+// programmer error.
+func (cmd *Command) AddOptionsFromStruct(group string, v any) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Errorf("Assertion failed: AddOptionsFromStruct requires a struct or non-nil pointer to a struct, instead found %T", v))
+	}
+	var opts []*Option
+	forEachTaggedField(rv, func(field reflect.StructField, value reflect.Value, tag optionTag) {
+		opts = append(opts, optionFromField(field, value, tag))
+	})
+	cmd.AddOptions(group, opts...)
+}
+
+// optionFromField returns a new Option corresponding to a tagged struct field,
+// as per AddOptionsFromStruct.
+func optionFromField(field reflect.StructField, value reflect.Value, tag optionTag) *Option {
+	var short rune
+	if s := field.Tag.Get("short"); s != "" {
+		if utf8.RuneCountInString(s) != 1 {
+			panic(fmt.Errorf("Assertion failed: field %s has short tag %q, which is not a single character", field.Name, s))
+		}
+		short, _ = utf8.DecodeRuneInString(s)
+	}
+	desc := field.Tag.Get("desc")
+
+	var opt *Option
+	switch field.Type {
+	case durationType:
+		opt = DurationOption(tag.name, short, time.Duration(value.Int()), desc)
+	case regexpType:
+		var defaultValue string
+		if !value.IsNil() {
+			defaultValue = value.Interface().(*regexp.Regexp).String()
+		}
+		opt = RegexpOption(tag.name, short, defaultValue, desc)
+	default:
+		switch field.Type.Kind() {
+		case reflect.String:
+			opt = StringOption(tag.name, short, value.String(), desc)
+		case reflect.Bool:
+			opt = BoolOption(tag.name, short, value.Bool(), desc)
+		case reflect.Int:
+			if tag.has("count") {
+				opt = CountOption(tag.name, short, int(value.Int()), desc)
+			} else {
+				opt = IntOption(tag.name, short, int(value.Int()), desc)
+			}
+		case reflect.Uint64:
+			opt = BytesOption(tag.name, short, strconv.FormatUint(value.Uint(), 10), desc)
+		case reflect.Float64:
+			opt = FloatOption(tag.name, short, value.Float(), desc)
+		case reflect.Slice:
+			if field.Type.Elem().Kind() == reflect.String {
+				opt = StringOption(tag.name, short, strings.Join(value.Convert(reflect.TypeFor[[]string]()).Interface().([]string), ","), desc)
+			}
+		case reflect.Map:
+			if field.Type.Key().Kind() == reflect.String && field.Type.Elem().Kind() == reflect.String {
+				pairs := make([]string, 0, value.Len())
+				for iter := value.MapRange(); iter.Next(); {
+					pairs = append(pairs, iter.Key().String()+"="+iter.Value().String())
+				}
+				sort.Strings(pairs)
+				opt = MapOption(tag.name, short, strings.Join(pairs, ","), desc)
+			}
+		}
+	}
+	if opt == nil {
+		panic(fmt.Errorf("Assertion failed: field %s has unsupported type %s for option %s", field.Name, field.Type, tag.name))
+	}
+
+	for _, flag := range tag.flags {
+		switch flag {
+		case "hidden":
+			opt.Hidden()
+		case "optional":
+			opt.ValueOptional()
+		case "repeatable":
+			opt.Repeatable()
+		case "count":
+			if opt.ValueType != ValueTypeCount {
+				panic(fmt.Errorf("Assertion failed: field %s has count flag but is not an int", field.Name))
+			}
+		default:
+			panic(fmt.Errorf("Assertion failed: field %s has unknown flag %q in %s tag", field.Name, flag, bindTag))
+		}
+	}
+	if details, ok := field.Tag.Lookup("deprecated"); ok {
+		opt.MarkDeprecated(details)
+	}
+	return opt
+}
@@ -0,0 +1,234 @@
+package mybase
+
+import (
+	"errors"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigBind(t *testing.T) {
+	type Level string
+	type connectionOptions struct {
+		Timeout time.Duration     `mybase:"timeout"`
+		Labels  map[string]string `mybase:"labels"`
+	}
+	type Embedded struct {
+		Ratio float64 `mybase:"ratio"`
+	}
+	type handlerOptions struct {
+		Embedded
+		Visible     string         `mybase:"visible"`
+		Level       Level          `mybase:"hasshort"`
+		Bool1       bool           `mybase:"bool1"`
+		Truthy      bool           `mybase:"truthybool"`
+		Concurrency int            `mybase:"concurrency"`
+		Verbosity   int            `mybase:"verbose"`
+		BufferSize  uint64         `mybase:"buffer-size"`
+		Pattern     *regexp.Regexp `mybase:"pattern"`
+		Tables      []string       `mybase:"ignore-table"`
+		Schemas     []string       `mybase:"schemas"`
+		Required    string         `mybase:"required"`
+		Connection  connectionOptions
+		Ignored     string `mybase:"-"`
+		Untagged    string
+	}
+
+	cmd := simpleCommand()
+	cmd.AddOption(IntOption("concurrency", 0, 5, "dummy description"))
+	cmd.AddOption(CountOption("verbose", 'v', 0, "dummy description"))
+	cmd.AddOption(BytesOption("buffer-size", 0, "1M", "dummy description"))
+	cmd.AddOption(RegexpOption("pattern", 0, "", "dummy description"))
+	cmd.AddOption(StringOption("ignore-table", 0, "", "dummy description").Repeatable())
+	cmd.AddOption(StringOption("schemas", 0, "", "dummy description"))
+	cmd.AddOption(DurationOption("timeout", 0, 30*time.Second, "dummy description"))
+	cmd.AddOption(MapOption("labels", 0, "", "dummy description"))
+	cmd.AddOption(FloatOption("ratio", 0, 0.5, "dummy description"))
+
+	cfg := ParseFakeCLI(t, cmd, "mycommand -vv -s debug --bool1 --pattern=^foo --ignore-table=a --ignore-table=b --schemas='x, y' --labels=env=prod --ratio=2.5 --timeout=1m arg1")
+	opts := handlerOptions{Ignored: "keep", Untagged: "keep"}
+	if err := cfg.Bind(&opts); err != nil {
+		t.Fatalf("Unexpected error from Bind: %v", err)
+	}
+	if opts.Visible != "" || opts.Level != "debug" || !opts.Bool1 || !opts.Truthy || opts.Concurrency != 5 || opts.Verbosity != 2 || opts.BufferSize != 1024*1024 || opts.Required != "arg1" {
+		t.Errorf("Unexpected scalar field values after Bind: %+v", opts)
+	}
+	if opts.Pattern == nil || !opts.Pattern.MatchString("foobar") {
+		t.Errorf("Unexpected Pattern after Bind: %v", opts.Pattern)
+	}
+	if !slices.Equal(opts.Tables, []string{"a", "b"}) || !slices.Equal(opts.Schemas, []string{"x", "y"}) {
+		t.Errorf("Unexpected slice field values after Bind: %q, %q", opts.Tables, opts.Schemas)
+	}
+	if opts.Connection.Timeout != time.Minute || !maps.Equal(opts.Connection.Labels, map[string]string{"env": "prod"}) || opts.Ratio != 2.5 {
+		t.Errorf("Unexpected nested field values after Bind: %+v, %v", opts.Connection, opts.Ratio)
+	}
+	if opts.Ignored != "keep" || opts.Untagged != "keep" {
+		t.Errorf("Bind unexpectedly modified fields without a tag: %+v", opts)
+	}
+
+	// Invalid values should be reported together, leaving those fields as-is
+	cfg = ParseFakeCLI(t, cmd, "mycommand --concurrency=lots --buffer-size=big --timeout=soon arg1")
+	opts = handlerOptions{Concurrency: 3}
+	err := cfg.Bind(&opts)
+	var ove OptionValueError
+	if err == nil || !errors.As(err, &ove) || ove.Name != "concurrency" {
+		t.Errorf("Expected OptionValueError from Bind, instead found %v", err)
+	} else if lines := strings.Split(err.Error(), "\n"); len(lines) != 3 {
+		t.Errorf("Expected error from Bind to have 3 lines, instead found %d: %v", len(lines), err)
+	}
+	if opts.Concurrency != 3 || opts.BufferSize != 0 || opts.Connection.Timeout != 0 || opts.Level != "" {
+		t.Errorf("Unexpected field values after Bind with errors: %+v", opts)
+	}
+
+	// Programmer errors should panic
+	assertPanic := func(dst any) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected Bind(%T) to panic, but it did not", dst)
+			}
+		}()
+		cfg.Bind(dst)
+	}
+	assertPanic(opts)
+	assertPanic((*handlerOptions)(nil))
+	assertPanic(&struct {
+		Foo string `mybase:"doesnt-exist"`
+	}{})
+	assertPanic(&struct {
+		Foo []int `mybase:"schemas"`
+	}{})
+	assertPanic(&struct {
+		foo string `mybase:"visible"`
+	}{})
+}
+
+func TestAddOptionsFromStruct(t *testing.T) {
+	type connectionOptions struct {
+		Host    string        `mybase:"host" short:"h" desc:"Database host"`
+		Timeout time.Duration `mybase:"timeout" desc:"Connection timeout"`
+	}
+	type handlerOptions struct {
+		Connection  connectionOptions
+		Concurrency int               `mybase:"concurrency" short:"c" desc:"Number of workers"`
+		Verbosity   int               `mybase:"verbose,count" short:"v"`
+		DryRun      bool              `mybase:"dry-run"`
+		Safe        bool              `mybase:"safe"`
+		BufferSize  uint64            `mybase:"buffer-size"`
+		Ratio       float64           `mybase:"ratio"`
+		Pattern     *regexp.Regexp    `mybase:"pattern,hidden"`
+		Tables      []string          `mybase:"ignore-table,repeatable"`
+		Labels      map[string]string `mybase:"labels"`
+		Password    string            `mybase:"password,optional"`
+		OldName     string            `mybase:"old-name" deprecated:"Use --host instead."`
+		Skipped     string            `mybase:"-"`
+	}
+	defaults := handlerOptions{
+		Connection:  connectionOptions{Host: "localhost", Timeout: 5 * time.Second},
+		Concurrency: 4,
+		Safe:        true,
+		BufferSize:  1024,
+		Pattern:     regexp.MustCompile("^foo"),
+		Tables:      []string{"a", "b"},
+		Labels:      map[string]string{"z": "1", "a": "2"},
+	}
+	cmd := NewCommand("mycommand", "summary", "description", nil)
+	cmd.AddOptionsFromStruct("Connection", defaults)
+	options := cmd.Options()
+	var count int
+	for _, opt := range options {
+		if opt.Group == "Connection" {
+			count++
+		}
+	}
+	if count != 13 {
+		t.Fatalf("Expected 13 options in group, instead found %d", count)
+	}
+	expectDefaults := map[string]string{
+		"host":         "localhost",
+		"timeout":      "5s",
+		"concurrency":  "4",
+		"verbose":      "",
+		"dry-run":      "",
+		"safe":         "1",
+		"buffer-size":  "1024",
+		"ratio":        "0",
+		"pattern":      "^foo",
+		"ignore-table": "a,b",
+		"labels":       "a=2,z=1",
+	}
+	for name, expected := range expectDefaults {
+		if opt := options[name]; opt.Default != expected || opt.Group != "Connection" {
+			t.Errorf("Unexpected Default or Group for option %s: %q, %q", name, opt.Default, opt.Group)
+		}
+	}
+	if opt := options["concurrency"]; opt.Shorthand != 'c' || opt.Description != "Number of workers" || opt.ValueType != ValueTypeInt {
+		t.Errorf("Unexpected fields in option %s: %+v", opt.Name, opt)
+	}
+	if opt := options["verbose"]; opt.ValueType != ValueTypeCount || opt.Shorthand != 'v' {
+		t.Errorf("Unexpected fields in option %s: %+v", opt.Name, opt)
+	}
+	if !options["pattern"].HiddenOnCLI || !options["ignore-table"].repeatable || options["password"].RequireValue || !options["host"].RequireValue {
+		t.Error("Tag flags did not have the expected effects")
+	}
+	if !options["old-name"].Deprecated() || options["host"].Deprecated() {
+		t.Error("Deprecated tag did not have the expected effect")
+	}
+	if options["labels"].ValueType != ValueTypeMap || options["buffer-size"].ValueType != ValueTypeBytes {
+		t.Error("Unexpected ValueType for map or bytes option")
+	}
+
+	// The same struct type should be usable as a binding target
+	cfg := ParseFakeCLI(t, cmd, "mycommand -h db1 -vvv --ignore-table=c --skip-safe")
+	var opts handlerOptions
+	if err := cfg.Bind(&opts); err != nil {
+		t.Fatalf("Unexpected error from Bind: %v", err)
+	}
+	if opts.Connection.Host != "db1" || opts.Connection.Timeout != 5*time.Second || opts.Verbosity != 3 || opts.Safe || opts.Concurrency != 4 {
+		t.Errorf("Unexpected field values after Bind: %+v", opts)
+	}
+	if !slices.Equal(opts.Tables, []string{"c"}) || !maps.Equal(opts.Labels, defaults.Labels) {
+		t.Errorf("Unexpected field values after Bind: %+v", opts)
+	}
+
+	// Slice defaults should be bound as their original elements, even for
+	// repeatable options which are read via GetAll when supplied
+	cfg = ParseFakeCLI(t, cmd, "mycommand")
+	opts = handlerOptions{}
+	if err := cfg.Bind(&opts); err != nil {
+		t.Fatalf("Unexpected error from Bind: %v", err)
+	}
+	if !slices.Equal(opts.Tables, defaults.Tables) {
+		t.Errorf("Unexpected default for repeatable slice field after Bind: expected %q, found %q", defaults.Tables, opts.Tables)
+	}
+
+	// Invalid tags should panic
+	assertPanic := func(v any) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected AddOptionsFromStruct(%T) to panic, but it did not", v)
+			}
+		}()
+		NewCommand("mycommand", "summary", "description", nil).AddOptionsFromStruct("", v)
+	}
+	assertPanic("not a struct")
+	assertPanic(struct {
+		Foo string `mybase:"foo" short:"ab"`
+	}{})
+	assertPanic(struct {
+		Foo string `mybase:"foo,bogus"`
+	}{})
+	assertPanic(struct {
+		Foo string `mybase:"foo,count"`
+	}{})
+	assertPanic(struct {
+		Foo bool `mybase:"foo,repeatable"`
+	}{})
+	assertPanic(struct {
+		Foo []int `mybase:"foo"`
+	}{})
+}
@@ -3,15 +3,19 @@ package mybase
 import (
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 )
 
 // CommandLine stores state relating to executing an application.
 type CommandLine struct {
-	InvokedAs    string            // How the bin was invoked; e.g. os.Args[0]
-	Command      *Command          // Which command (or subcommand) is being executed
-	OptionValues map[string]string // Option values parsed from the command-line
-	ArgValues    []string          // Positional arg values (does not include InvokedAs or Command.Name)
+	InvokedAs    string              // How the bin was invoked; e.g. os.Args[0]
+	Command      *Command            // Which command (or subcommand) is being executed
+	OptionValues map[string]string   // Option values parsed from the command-line
+	ArgValues    []string            // Positional arg values (does not include InvokedAs or Command.Name)
+	repeated     map[string][]string // All values of repeatable options, in the order supplied on the command-line
+	completion   *completionRequest  // Non-nil if command-line was a request for tab completion candidates
 }
 
 // OptionValue returns the value for the requested option if it was specified
@@ -22,6 +26,19 @@ func (cli *CommandLine) OptionValue(optionName string) (string, bool) {
 	return value, ok
 }
 
+// AllOptionValues returns every value supplied on the command-line for the
+// requested option, in the order supplied. Only options marked as Repeatable
+// retain more than one value. This satisfies the MultiOptionValuer interface.
+func (cli *CommandLine) AllOptionValues(optionName string) ([]string, bool) {
+	if values, ok := cli.repeated[optionName]; ok {
+		return slices.Clone(values), true
+	}
+	if value, ok := cli.OptionValues[optionName]; ok {
+		return []string{value}, true
+	}
+	return nil, false
+}
+
 // DeprecationWarnings returns a slice of warning messages for usage of
 // deprecated options on the command-line. This satisfies the DeprecationWarner
 // interface.
@@ -57,6 +74,8 @@ func (cli *CommandLine) parseLongArg(arg string, args *[]string, longOptionIndex
 			}
 			value = (*args)[0]
 			*args = (*args)[1:]
+		} else if opt.ValueType == ValueTypeCount {
+			value = cli.incrementedCount(opt)
 		} else if opt.Type == OptionTypeBool {
 			// Boolean without value is treated as true
 			value = "1"
@@ -69,7 +88,11 @@ func (cli *CommandLine) parseLongArg(arg string, args *[]string, longOptionIndex
 		value = "''"
 	}
 
-	cli.OptionValues[opt.Name] = value
+	value, err := opt.ingestValue(value, cli, "")
+	if err != nil {
+		return err
+	}
+	cli.setValue(opt, value)
 	return nil
 }
 
@@ -98,16 +121,42 @@ func (cli *CommandLine) parseShortArgs(arg string, args *[]string, shortOptionIn
 				return OptionMissingValueError{opt.Name, "CLI"}
 			}
 		} else { // "-xyz", parse x as a valueless option and loop again to parse y (and possibly z) as separate shorthand options
-			if opt.Type == OptionTypeBool {
+			if opt.ValueType == ValueTypeCount {
+				value = cli.incrementedCount(opt)
+			} else if opt.Type == OptionTypeBool {
 				value = "1" // booleans handle lack of value as being true, whereas other types keep it as empty string
 			}
 		}
 
-		cli.OptionValues[opt.Name] = value
+		value, err := opt.ingestValue(value, cli, "")
+		if err != nil {
+			return err
+		}
+		cli.setValue(opt, value)
 	}
 	return nil
 }
 
+// setValue stores the value of an option supplied on the command-line. If the
+// option is repeatable, the value is also appended to any previous values.
+func (cli *CommandLine) setValue(opt *Option, value string) {
+	cli.OptionValues[opt.Name] = value
+	if opt.repeatable {
+		if cli.repeated == nil {
+			cli.repeated = make(map[string][]string)
+		}
+		cli.repeated[opt.Name] = append(cli.repeated[opt.Name], value)
+	}
+}
+
+// incrementedCount returns the value for an occurrence of a valueless option
+// created by CountOption, which is one more than any count previously supplied
+// on the command-line.
+func (cli *CommandLine) incrementedCount(opt *Option) string {
+	n, _ := countValue(cli.OptionValues[opt.Name])
+	return strconv.Itoa(n + 1)
+}
+
 func (cli *CommandLine) String() string {
 	// Don't reveal the actual command-line value, since it may contain something
 	// sensitive (even though it shouldn't!)
@@ -123,6 +172,10 @@ func (cli *CommandLine) String() string {
 //
 // The supplied args should match format of os.Args; i.e. args[0]
 // should contain the program name.
+//
+// If args[1] is "__complete", the command-line is instead treated as a request
+// for tab completion candidates by a shell completion script, and parsing is
+// lenient; see Option.CompleteWith for more information.
 func ParseCLI(cmd *Command, args []string) (*Config, error) {
 	if len(args) == 0 {
 		return nil, errors.New("ParseCLI: No command-line supplied")
@@ -136,20 +189,44 @@ func ParseCLI(cmd *Command, args []string) (*Config, error) {
 	}
 	args = args[1:]
 
+	if len(args) > 0 && args[0] == completeArg {
+		cli.parseCompletionArgs(args[1:])
+		return NewConfig(cli), nil
+	}
+
+	if _, err := cli.parseArgs(args, false); err != nil {
+		return nil, err
+	}
+
+	if _, helpWanted := cli.OptionValues["help"]; !helpWanted && len(cli.ArgValues) < cli.Command.minArgs() {
+		return nil, fmt.Errorf("Too few positional args supplied on command line; command %s requires at least %d args", cli.Command.Name, cli.Command.minArgs())
+	}
+
+	// If no command supplied on a command suite, redirect to help subcommand
+	if len(cli.Command.SubCommands) > 0 {
+		cli.Command = cli.Command.SubCommands["help"]
+	}
+
+	return NewConfig(cli), nil
+}
+
+// parseArgs iterates over args, storing option values and positional arg values
+// in cli, and descending into subcommands as needed. If lenient is true,
+// problematic args are skipped instead of returning an error. Returns a bool
+// indicating whether an option terminator ("--") was encountered.
+func (cli *CommandLine) parseArgs(args []string, lenient bool) (noMoreOptions bool, err error) {
 	// Index options by shorthand
-	longOptionIndex := cmd.Options()
+	longOptionIndex := cli.Command.Options()
 	shortOptionIndex := make(map[rune]*Option, len(longOptionIndex))
 	for name, opt := range longOptionIndex {
 		if opt.Shorthand != 0 {
 			if _, already := shortOptionIndex[opt.Shorthand]; already {
-				panic(fmt.Errorf("Command %s defines multiple conflicting options with short-form -%c", cmd.Name, opt.Shorthand))
+				panic(fmt.Errorf("Command %s defines multiple conflicting options with short-form -%c", cli.Command.Name, opt.Shorthand))
 			}
 			shortOptionIndex[opt.Shorthand] = longOptionIndex[name]
 		}
 	}
 
-	var noMoreOptions bool
-
 	// Iterate over the cli args and process each in turn
 	for len(args) > 0 {
 		arg := args[0]
@@ -161,21 +238,18 @@ func ParseCLI(cmd *Command, args []string) (*Config, error) {
 
 		// long option
 		case len(arg) > 2 && arg[0:2] == "--" && !noMoreOptions:
-			if err := cli.parseLongArg(arg[2:], &args, longOptionIndex); err != nil {
-				return nil, err
-			}
+			err = cli.parseLongArg(arg[2:], &args, longOptionIndex)
 
 		// short option(s) -- multiple bools may be combined into one
 		case len(arg) > 1 && arg[0] == '-' && !noMoreOptions:
-			if err := cli.parseShortArgs(arg[1:], &args, shortOptionIndex); err != nil {
-				return nil, err
-			}
+			err = cli.parseShortArgs(arg[1:], &args, shortOptionIndex)
 
 		// first positional arg is command name if the current command is a command suite
 		case len(cli.Command.SubCommands) > 0:
-			command, validCommand := cli.Command.SubCommands[arg]
-			if !validCommand {
-				return nil, fmt.Errorf("Unknown command \"%s\"", arg)
+			command := cli.Command.subCommand(arg)
+			if command == nil {
+				err = fmt.Errorf("Unknown command \"%s\"", arg)
+				break
 			}
 			cli.Command = command
 
@@ -191,28 +265,20 @@ func ParseCLI(cmd *Command, args []string) (*Config, error) {
 		// supplying help or version as first positional arg to a non-command-suite:
 		// treat as if supplied as option instead
 		case len(cli.ArgValues) == 0 && (arg == "help" || arg == "version"):
-			if err := cli.parseLongArg(arg, &args, longOptionIndex); err != nil {
-				return nil, err
-			}
+			err = cli.parseLongArg(arg, &args, longOptionIndex)
 
 		// superfluous positional arg
-		case len(cli.ArgValues) >= len(cli.Command.args):
-			return nil, fmt.Errorf("Extra command-line arg \"%s\" supplied; command %s takes a max of %d args", arg, cli.Command.Name, len(cli.Command.args))
+		case len(cli.ArgValues) >= len(cli.Command.args) && !cli.Command.variadicArg:
+			err = fmt.Errorf("Extra command-line arg \"%s\" supplied; command %s takes a max of %d args", arg, cli.Command.Name, len(cli.Command.args))
 
 		// positional arg
 		default:
 			cli.ArgValues = append(cli.ArgValues, arg)
 		}
-	}
-
-	if _, helpWanted := cli.OptionValues["help"]; !helpWanted && len(cli.ArgValues) < cli.Command.minArgs() {
-		return nil, fmt.Errorf("Too few positional args supplied on command line; command %s requires at least %d args", cli.Command.Name, cli.Command.minArgs())
-	}
 
-	// If no command supplied on a command suite, redirect to help subcommand
-	if len(cli.Command.SubCommands) > 0 {
-		cli.Command = cli.Command.SubCommands["help"]
+		if err != nil && !lenient {
+			return noMoreOptions, err
+		}
 	}
-
-	return NewConfig(cli), nil
+	return noMoreOptions, nil
 }
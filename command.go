@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime"
+	"slices"
 	"sort"
 	"strings"
 
@@ -28,6 +29,11 @@ type Command struct {
 	Handler       CommandHandler      // Callback for processing command. Ignored if len(SubCommands) > 0.
 	options       map[string]*Option  // Command-specific options
 	args          []*Option           // command-speciifc positional args. Ignored if len(SubCommands) > 0.
+	aliases       []string            // alternate names for this command, as used in CLI
+	variadicArg   bool                // true if the final entry in args collects all remaining positional args
+	variadicMin   int                 // minimum number of values required for variadic arg
+	builtIn       bool                // true for automatically-added subcommands such as help, which skip option validation
+	printConfig   bool                // true if AddPrintConfigOption was called on this command
 }
 
 // NewCommand creates a standalone command, ie one that does not take sub-
@@ -68,6 +74,7 @@ func NewCommandSuite(name, summary, description string) *Command {
 		Description: "Display usage information",
 		Summary:     `Display usage information`,
 		Handler:     helpHandler,
+		builtIn:     true,
 	}
 	helpCmd.AddArg("command", "", false)
 
@@ -77,6 +84,7 @@ func NewCommandSuite(name, summary, description string) *Command {
 		Description: "Display program version",
 		Summary:     `Display program version`,
 		Handler:     versionHandler,
+		builtIn:     true,
 	}
 
 	cmd.AddSubCommand(versionCmd)
@@ -89,23 +97,81 @@ func NewCommandSuite(name, summary, description string) *Command {
 	return cmd
 }
 
-// AddSubCommand adds a subcommand to a command suite.
+// AddSubCommand adds a subcommand to a command suite. Panics if the subcommand's
+// name or aliases conflict with the aliases or name of another subcommand.
 func (cmd *Command) AddSubCommand(subCmd *Command) {
 	if cmd.SubCommands == nil || cmd.Handler != nil {
 		panic(fmt.Errorf("AddSubCommand: Parent command %s was not created as a CommandSuite", cmd.Name))
 	}
+	for _, sibling := range cmd.SubCommands {
+		if sibling.Name == subCmd.Name {
+			continue // subCmd will replace sibling
+		}
+		if slices.Contains(sibling.aliases, subCmd.Name) {
+			panic(fmt.Errorf("AddSubCommand: Command %s name conflicts with an alias of command %s", subCmd.Name, sibling.Name))
+		}
+		for _, alias := range subCmd.aliases {
+			if alias == sibling.Name || slices.Contains(sibling.aliases, alias) {
+				panic(fmt.Errorf("AddSubCommand: Command %s alias %s conflicts with command %s", subCmd.Name, alias, sibling.Name))
+			}
+		}
+	}
 	subCmd.ParentCommand = cmd
 	cmd.SubCommands[subCmd.Name] = subCmd
 	delete(subCmd.SubCommands, "version") // non-top-level command suites don't need version as command
 }
 
+// AddAlias adds one or more alternate names for a subcommand, which may be used
+// in place of its Name on the command-line. Aliases may be added either before
+// or after the subcommand is added to its parent command suite. Panics if an
+// alias is a duplicate, or conflicts with the name or aliases of another
+// subcommand of the same parent.
+func (cmd *Command) AddAlias(names ...string) {
+	for _, alias := range names {
+		if alias == cmd.Name || slices.Contains(cmd.aliases, alias) {
+			panic(fmt.Errorf("AddAlias: Command %s already has name or alias %s", cmd.Name, alias))
+		}
+		if cmd.ParentCommand != nil {
+			for _, sibling := range cmd.ParentCommand.SubCommands {
+				if sibling != cmd && (alias == sibling.Name || slices.Contains(sibling.aliases, alias)) {
+					panic(fmt.Errorf("AddAlias: Command %s alias %s conflicts with command %s", cmd.Name, alias, sibling.Name))
+				}
+			}
+		}
+		cmd.aliases = append(cmd.aliases, alias)
+	}
+}
+
+// Aliases returns the alternate names which have been added for cmd via
+// AddAlias.
+func (cmd *Command) Aliases() []string {
+	return slices.Clone(cmd.aliases)
+}
+
+// subCommand returns the subcommand of cmd with the supplied name or alias, or
+// nil if there is no such subcommand.
+func (cmd *Command) subCommand(name string) *Command {
+	if subCmd, ok := cmd.SubCommands[name]; ok {
+		return subCmd
+	}
+	for _, subCmd := range cmd.SubCommands {
+		if slices.Contains(subCmd.aliases, name) {
+			return subCmd
+		}
+	}
+	return nil
+}
+
 // AddArg adds a positional arg to a Command. If requireValue is false, this arg
 // is considered optional and its defaultValue will be used if omitted.
 func (cmd *Command) AddArg(name, defaultValue string, requireValue bool) {
 	// Validate the arg. Panic if there's a problem, since this is indicative of
 	// programmer error.
+	if cmd.variadicArg {
+		panic(fmt.Errorf("Cannot add arg %s to command %s: prior arg is variadic", name, cmd.Name))
+	}
 	for _, arg := range cmd.args {
-		// Cannot add two args with same name (TODO: add support for arg slurping into a slice)
+		// Cannot add two args with same name
 		if arg.Name == name {
 			panic(fmt.Errorf("Cannot add arg %s to command %s: prior arg already has that name", name, cmd.Name))
 		}
@@ -128,6 +194,33 @@ func (cmd *Command) AddArg(name, defaultValue string, requireValue bool) {
 	cmd.args = append(cmd.args, arg)
 }
 
+// AddVariadicArg adds a final positional arg to a Command, which collects all
+// remaining positional arg values supplied on the command-line. At least
+// minCount values must be supplied; if minCount is 0, the arg is optional. Use
+// Config.GetArgs to obtain the supplied values. No other args may be added to
+// the Command after a variadic arg.
+func (cmd *Command) AddVariadicArg(name string, minCount int) {
+	if minCount < 0 {
+		panic(fmt.Errorf("Cannot add variadic arg %s to command %s: minCount cannot be negative", name, cmd.Name))
+	}
+	cmd.AddArg(name, "", minCount > 0)
+	cmd.variadicArg = true
+	cmd.variadicMin = minCount
+}
+
+// CompleteArgWith sets a callback for dynamically determining tab completion
+// candidates for the value of the positional arg with the supplied name. See
+// Option.CompleteWith for more information. Panics if cmd has no such arg.
+func (cmd *Command) CompleteArgWith(name string, fn CompletionFunc) {
+	for _, arg := range cmd.args {
+		if arg.Name == name {
+			arg.CompleteWith(fn)
+			return
+		}
+	}
+	panic(fmt.Errorf("Cannot set completion callback for arg %s: command %s has no such arg", name, cmd.Name))
+}
+
 // AddOption adds an Option to a Command. Options represent flags/settings
 // which can be supplied via the command-line or an options file.
 func (cmd *Command) AddOption(opt *Option) {
@@ -209,15 +302,20 @@ func (cmd *Command) Usage() {
 		fmt.Println("\nCommands:")
 		var maxLen int
 		names := make([]string, 0, len(cmd.SubCommands))
-		for name := range cmd.SubCommands {
+		displayNames := make(map[string]string, len(cmd.SubCommands))
+		for name, subCmd := range cmd.SubCommands {
 			names = append(names, name)
-			if len(name) > maxLen {
-				maxLen = len(name)
+			displayNames[name] = name
+			if len(subCmd.aliases) > 0 {
+				displayNames[name] = fmt.Sprintf("%s (%s)", name, strings.Join(subCmd.aliases, ", "))
+			}
+			if len(displayNames[name]) > maxLen {
+				maxLen = len(displayNames[name])
 			}
 		}
 		sort.Strings(names)
 		for _, name := range names {
-			fmt.Printf("      %*s  %s\n", -1*maxLen, name, cmd.SubCommands[name].Summary)
+			fmt.Printf("      %*s  %s\n", -1*maxLen, displayNames[name], cmd.SubCommands[name].Summary)
 		}
 	}
 
@@ -355,10 +453,23 @@ func (cmd *Command) minArgs() int {
 			return n
 		}
 	}
-	// If all args are required, the min arg count is the number of args.
+	// If all args are required, the min arg count is the number of args, with
+	// a required variadic arg potentially requiring more than one value.
+	if cmd.variadicArg {
+		return len(cmd.args) - 1 + cmd.variadicMin
+	}
 	return len(cmd.args)
 }
 
+// variadicArgName returns the name of cmd's variadic arg, or an empty string if
+// cmd does not have one.
+func (cmd *Command) variadicArgName() string {
+	if !cmd.variadicArg {
+		return ""
+	}
+	return cmd.args[len(cmd.args)-1].Name
+}
+
 func (cmd *Command) argUsage() string {
 	if len(cmd.SubCommands) > 0 {
 		return " <command>"
@@ -366,11 +477,15 @@ func (cmd *Command) argUsage() string {
 
 	var usage string
 	var optionalArgs int
-	for _, arg := range cmd.args {
+	for n, arg := range cmd.args {
+		var ellipsis string
+		if cmd.variadicArg && n == len(cmd.args)-1 {
+			ellipsis = "..."
+		}
 		if arg.RequireValue {
-			usage += fmt.Sprintf(" <%s>", arg.Name)
+			usage += fmt.Sprintf(" <%s>%s", arg.Name, ellipsis)
 		} else {
-			usage += fmt.Sprintf(" [<%s>", arg.Name)
+			usage += fmt.Sprintf(" [<%s>%s", arg.Name, ellipsis)
 			optionalArgs++
 		}
 	}
@@ -387,8 +502,7 @@ func helpHandler(cfg *Config) error {
 		forCommandName = unquote(cfg.CLI.ArgValues[0])
 	}
 	if len(forCommand.SubCommands) > 0 && forCommandName != "" {
-		var ok bool
-		if forCommand, ok = forCommand.SubCommands[forCommandName]; !ok {
+		if forCommand = forCommand.subCommand(forCommandName); forCommand == nil {
 			return fmt.Errorf("Unknown command \"%s\"", forCommandName)
 		}
 	}
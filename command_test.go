@@ -2,6 +2,7 @@ package mybase
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -28,6 +29,39 @@ func TestCommandInvocation(t *testing.T) {
 	if actual := subTwo.Invocation(); actual != expected {
 		t.Errorf("Incorrect result from Invocation() for subcommand two: expected=%q, actual=%q", expected, actual)
 	}
+
+	variadic := NewCommand("mycommand", "summary", "description", nil)
+	variadic.AddArg("required", "", true)
+	variadic.AddVariadicArg("paths", 1)
+	expected = "mycommand [<options>] <required> <paths>..."
+	if actual := variadic.Invocation(); actual != expected {
+		t.Errorf("Incorrect result from Invocation() for variadic command: expected=%q, actual=%q", expected, actual)
+	}
+	variadic = NewCommand("mycommand", "summary", "description", nil)
+	variadic.AddArg("optional", "", false)
+	variadic.AddVariadicArg("paths", 0)
+	expected = "mycommand [<options>] [<optional> [<paths>...]]"
+	if actual := variadic.Invocation(); actual != expected {
+		t.Errorf("Incorrect result from Invocation() for variadic command: expected=%q, actual=%q", expected, actual)
+	}
+}
+
+func TestCommandAddVariadicArg(t *testing.T) {
+	assertPanic := func(desc string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected %s to panic, but it did not", desc)
+			}
+		}()
+		fn()
+	}
+	cmd := NewCommand("mycommand", "summary", "description", nil)
+	cmd.AddArg("optional", "", false)
+	assertPanic("required variadic arg after optional arg", func() { cmd.AddVariadicArg("paths", 2) })
+	assertPanic("negative minCount", func() { cmd.AddVariadicArg("paths", -1) })
+	cmd.AddVariadicArg("paths", 0)
+	assertPanic("arg after variadic arg", func() { cmd.AddArg("another", "", false) })
 }
 
 func TestCommandOptionGroups(t *testing.T) {
@@ -100,6 +134,58 @@ func TestWebDocText(t *testing.T) {
 	}
 }
 
+func TestCommandAliases(t *testing.T) {
+	assertPanic := func(desc string, fn func()) {
+		t.Helper()
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected %s to panic, but it did not", desc)
+			}
+		}()
+		fn()
+	}
+
+	suite := simpleCommandSuite()
+	suite.SubCommands["one"].AddAlias("uno", "1")
+	cmd3 := NewCommand("three", "summary", "description", nil)
+	cmd3.AddAlias("tres")
+	suite.AddSubCommand(cmd3)
+	if aliases := suite.SubCommands["one"].Aliases(); !slices.Equal(aliases, []string{"uno", "1"}) {
+		t.Errorf("Unexpected return from Aliases(): %v", aliases)
+	}
+
+	cfg := ParseFakeCLI(t, suite, "mycommand uno --newopt=foo")
+	if cfg.CLI.Command != suite.SubCommands["one"] || cfg.Get("newopt") != "foo" {
+		t.Errorf("Alias did not resolve to expected command; found command %s", cfg.CLI.Command.Name)
+	}
+	if actual := cfg.CLI.Command.WebDocText(); !strings.HasSuffix(actual, "/one") {
+		t.Errorf("Expected web doc link to use canonical command name, instead found %q", actual)
+	}
+	cfg = ParseFakeCLI(t, suite, "mycommand tres")
+	if cfg.CLI.Command != cmd3 {
+		t.Errorf("Alias did not resolve to expected command; found command %s", cfg.CLI.Command.Name)
+	}
+	if sub := suite.subCommand("1"); sub != suite.SubCommands["one"] {
+		t.Errorf("Unexpected return from subCommand: %v", sub)
+	}
+	if sub := suite.subCommand("doesnt-exist"); sub != nil {
+		t.Errorf("Unexpected return from subCommand: %v", sub)
+	}
+
+	assertPanic("duplicate alias", func() { cmd3.AddAlias("tres") })
+	assertPanic("alias same as own name", func() { cmd3.AddAlias("three") })
+	assertPanic("alias same as sibling name", func() { cmd3.AddAlias("two") })
+	assertPanic("alias same as sibling alias", func() { cmd3.AddAlias("uno") })
+	assertPanic("name same as sibling alias", func() {
+		suite.AddSubCommand(NewCommand("tres", "summary", "description", nil))
+	})
+	assertPanic("new command alias same as sibling name", func() {
+		cmd4 := NewCommand("four", "summary", "description", nil)
+		cmd4.AddAlias("one")
+		suite.AddSubCommand(cmd4)
+	})
+}
+
 // simpleCommand returns a standalone command for testing purposes
 func simpleCommand() *Command {
 	cmd := NewCommand("mycommand", "summary", "description", nil)
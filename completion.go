@@ -0,0 +1,580 @@
+package mybase
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// CompletionScript returns a script providing tab completion of cmd's
+// subcommands and options, for the supplied shell, which must be one of "bash",
+// "zsh", or "fish". The script always covers the full command tree, beginning
+// at cmd's root command. Options which are hidden or deprecated are omitted
+// from completion candidates. The returned script may be sourced directly by
+// the shell, or saved to the shell's usual location for completion scripts.
+func (cmd *Command) CompletionScript(shell string) (string, error) {
+	root := cmd.Root()
+	switch shell {
+	case "bash":
+		return bashCompletionScript(root), nil
+	case "zsh":
+		return zshCompletionScript(root), nil
+	case "fish":
+		return fishCompletionScript(root), nil
+	default:
+		return "", fmt.Errorf("Unsupported shell \"%s\" for completion script; supported shells are bash, zsh, and fish", shell)
+	}
+}
+
+// AddCompletionCommand adds a "completion" subcommand to a command suite. This
+// subcommand takes a shell name as its arg, and outputs a completion script for
+// that shell, as per CompletionScript. Panics if cmd is not a command suite.
+func (cmd *Command) AddCompletionCommand() {
+	completionCmd := &Command{
+		Name:        "completion",
+		Summary:     "Output shell completion script",
+		Description: "Output a tab completion script for the specified shell, which may be bash, zsh, or fish. For example, bash users may run `source <(" + cmd.Root().Name + " completion bash)` to enable tab completion in the current shell session.",
+		Handler:     completionHandler,
+		builtIn:     true,
+	}
+	completionCmd.AddArg("shell", "", true)
+	completionCmd.CompleteArgWith("shell", func(string, *Config) []string {
+		return []string{"bash", "fish", "zsh"}
+	})
+	cmd.AddSubCommand(completionCmd)
+}
+
+func completionHandler(cfg *Config) error {
+	script, err := cfg.CLI.Command.CompletionScript(cfg.Get("shell"))
+	if err != nil {
+		return err
+	}
+	fmt.Print(script)
+	return nil
+}
+
+// completeArg is a hidden first arg which indicates that a command-line is
+// a request from a shell completion script for tab completion candidates,
+// rather than a normal invocation of the program.
+const completeArg = "__complete"
+
+// CompletionFunc is a callback which returns tab completion candidates for the
+// value of an option or positional arg. It is supplied the partial value typed
+// so far, along with a Config reflecting the rest of the command-line, as well
+// as any other option sources added to the Config prior to calling
+// Config.HandleCommand. Candidates which do not begin with partial are filtered
+// out automatically, so the callback may ignore partial if desired.
+type CompletionFunc func(partial string, cfg *Config) []string
+
+// completionRequest stores state relating to a command-line which is a request
+// for tab completion candidates.
+type completionRequest struct {
+	partial string   // portion of the current token being completed
+	prefix  string   // portion of the current token preceding partial, e.g. "--host="
+	opt     *Option  // option or arg whose value is being completed, or nil if none
+	words   []string // static candidates, if completing option names or subcommand names
+}
+
+// candidates returns the tab completion candidates for req.
+func (req *completionRequest) candidates(cfg *Config) []string {
+	words := req.words
+	if req.opt != nil && req.opt.completer != nil {
+		words = req.opt.completer(req.partial, cfg)
+	}
+	var result []string
+	for _, word := range words {
+		if strings.HasPrefix(word, req.partial) {
+			result = append(result, req.prefix+word)
+		}
+	}
+	return result
+}
+
+// parseCompletionArgs handles a command-line which is a request for tab
+// completion candidates. The final element of args is the token being
+// completed, which may be an empty string. Preceding elements are parsed
+// leniently, to determine the subcommand, option values, and arg values
+// supplied so far.
+func (cli *CommandLine) parseCompletionArgs(args []string) {
+	req := &completionRequest{}
+	cli.completion = req
+	if len(args) > 0 {
+		req.partial = args[len(args)-1]
+		args = args[:len(args)-1]
+	}
+	noMoreOptions, _ := cli.parseArgs(args, true)
+
+	// If the preceding token is an option which requires a value, but lacks one,
+	// then the current token is that option's value
+	if len(args) > 0 && !noMoreOptions {
+		if opt := cli.Command.pendingValueOption(args[len(args)-1]); opt != nil {
+			req.opt = opt
+			return
+		}
+	}
+
+	switch {
+	case !noMoreOptions && strings.HasPrefix(req.partial, "--") && strings.Contains(req.partial, "="):
+		name, value, _ := strings.Cut(req.partial, "=")
+		key, _, _, _ := NormalizeOptionToken(name[2:])
+		req.opt = cli.Command.Options()[key]
+		req.prefix = name + "="
+		req.partial = value
+	case !noMoreOptions && strings.HasPrefix(req.partial, "-"):
+		for _, opt := range completionOptions(cli.Command) {
+			req.words = append(req.words, optionCompletionWords(opt)...)
+		}
+	case len(cli.Command.SubCommands) > 0:
+		req.words = sortedSubCommandNames(cli.Command)
+	case len(cli.ArgValues) < len(cli.Command.args):
+		req.opt = cli.Command.args[len(cli.ArgValues)]
+	case cli.Command.variadicArg:
+		req.opt = cli.Command.args[len(cli.Command.args)-1]
+	}
+}
+
+// pendingValueOption returns the option which requires a value, if token
+// consists of that option's long or short name without any value. Otherwise,
+// nil is returned.
+func (cmd *Command) pendingValueOption(token string) *Option {
+	options := cmd.Options()
+	if len(token) > 2 && strings.HasPrefix(token, "--") {
+		key, _, hasValue, _ := NormalizeOptionToken(token[2:])
+		if opt := options[key]; opt != nil && opt.RequireValue && !hasValue {
+			return opt
+		}
+	} else if len(token) > 1 && token[0] == '-' {
+		// Short options may be combined, but only the final one could be lacking a
+		// value
+		shortOptionIndex := make(map[rune]*Option, len(options))
+		for _, opt := range options {
+			if opt.Shorthand != 0 {
+				shortOptionIndex[opt.Shorthand] = opt
+			}
+		}
+		runeList := []rune(token[1:])
+		for n, short := range runeList {
+			opt := shortOptionIndex[short]
+			if opt == nil {
+				return nil
+			} else if opt.Type != OptionTypeBool {
+				if n == len(runeList)-1 && opt.RequireValue {
+					return opt
+				}
+				return nil
+			}
+		}
+	}
+	return nil
+}
+
+// hasCompleter returns true if opt has a CompletionFunc.
+func hasCompleter(opt *Option) bool {
+	return opt.completer != nil
+}
+
+// hasDynamicCompletion returns true if any option or positional arg of cmds has
+// a CompletionFunc. Completion scripts only include logic for invoking the
+// program to obtain completion candidates if this is the case.
+func hasDynamicCompletion(cmds []completionCommand) bool {
+	for _, c := range cmds {
+		if slices.ContainsFunc(c.args, hasCompleter) {
+			return true
+		}
+		for _, opt := range c.options {
+			if hasCompleter(opt) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// completionCommand represents one command within a command tree, for purposes
+// of generating shell completion scripts.
+type completionCommand struct {
+	*Command
+	path   string   // space-separated canonical names leading to this command from the root, or "" for the root itself
+	parent string   // path of the parent command
+	names  []string // name and aliases which select this command on the command-line
+}
+
+// completionCommands returns the full command tree beginning at root, in
+// breadth-first order, with subcommands of each command sorted by name.
+func completionCommands(root *Command) []completionCommand {
+	result := []completionCommand{{Command: root}}
+	for n := 0; n < len(result); n++ {
+		cur := result[n]
+		for _, name := range sortedSubCommandNames(cur.Command) {
+			subCmd := cur.SubCommands[name]
+			result = append(result, completionCommand{
+				Command: subCmd,
+				path:    strings.TrimSpace(cur.path + " " + name),
+				parent:  cur.path,
+				names:   append([]string{name}, subCmd.aliases...),
+			})
+		}
+	}
+	return result
+}
+
+func sortedSubCommandNames(cmd *Command) []string {
+	names := make([]string, 0, len(cmd.SubCommands))
+	for name := range cmd.SubCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// completionOptions returns the options of cmd which should be offered as
+// completion candidates, sorted by name. Hidden and deprecated options are
+// omitted.
+func completionOptions(cmd *Command) []*Option {
+	var opts []*Option
+	for _, opt := range cmd.Options() {
+		if !opt.HiddenOnCLI && !opt.Deprecated() {
+			opts = append(opts, opt)
+		}
+	}
+	sort.Slice(opts, func(i, j int) bool {
+		return opts[i].Name < opts[j].Name
+	})
+	return opts
+}
+
+// valueOptionTokens returns the command-line tokens which cause the following
+// token to be consumed as an option value, for example "--host" or "-h" if
+// option host requires a value. Completion scripts use this to avoid treating
+// option values as subcommand names. Hidden and deprecated options are
+// included, since they still affect parsing. Tokens which apply to every
+// command in cmds are returned in global; others are returned in local, in
+// format "path:token".
+func valueOptionTokens(cmds []completionCommand) (global, local []string) {
+	tokenPaths := make(map[string][]string)
+	for _, c := range cmds {
+		for name, opt := range c.Options() {
+			if opt.RequireValue {
+				tokenPaths["--"+name] = append(tokenPaths["--"+name], c.path)
+				if opt.Shorthand != 0 {
+					tokenPaths["-"+string(opt.Shorthand)] = append(tokenPaths["-"+string(opt.Shorthand)], c.path)
+				}
+			}
+		}
+	}
+	for token, paths := range tokenPaths {
+		if len(paths) == len(cmds) {
+			global = append(global, token)
+		} else {
+			for _, path := range paths {
+				local = append(local, path+":"+token)
+			}
+		}
+	}
+	sort.Strings(global)
+	sort.Strings(local)
+	return global, local
+}
+
+// optionCompletionWords returns the long and short forms of opt which may be
+// completed on the command-line, including the "skip-" negated form of boolean
+// options.
+func optionCompletionWords(opt *Option) []string {
+	words := []string{"--" + opt.Name}
+	if opt.Type == OptionTypeBool {
+		words = append(words, "--skip-"+opt.Name)
+	}
+	if opt.Shorthand != 0 {
+		words = append(words, "-"+string(opt.Shorthand))
+	}
+	return words
+}
+
+// shellIdentifier converts s into a string usable as part of a shell function
+// name.
+func shellIdentifier(s string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
+
+// shellQuote returns s wrapped in single quotes, as per bash and zsh quoting
+// rules.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote returns s wrapped in single quotes, as per fish quoting rules.
+func fishQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
+
+// completionDescription returns desc collapsed onto a single line.
+func completionDescription(desc string) string {
+	return strings.Join(strings.Fields(desc), " ")
+}
+
+// writeCommandPathLoop writes bash or zsh logic for determining the current
+// command path, by iterating over the words preceding the cursor. Both shells
+// use the same syntax for this, aside from the first index of the word array.
+// In bash, "=" is a word break character by default, so a long option and its
+// value may be split into three words; the loop handles this by skipping the
+// word after any "=".
+func writeCommandPathLoop(b *strings.Builder, cmds []completionCommand, wordsVar string, firstIndex, cursorVar string) {
+	fmt.Fprintf(b, "\tfor ((i = %s; i < %s; i++)); do\n", firstIndex, cursorVar)
+	fmt.Fprintf(b, "\t\tword=\"${%s[i]}\"\n", wordsVar)
+	b.WriteString("\t\tif ((skip)); then\n\t\t\tskip=0\n\t\t\t[[ $word == \"=\" ]] && skip=1\n\t\t\tcontinue\n\t\tfi\n")
+	b.WriteString("\t\tcase \"$word\" in\n\t\t'=')\n\t\t\tskip=1\n\t\t\tcontinue\n\t\t\t;;\n\t\t-*)\n")
+	global, local := valueOptionTokens(cmds)
+	var patterns []string
+	for _, token := range global {
+		patterns = append(patterns, "*"+shellQuote(":"+token))
+	}
+	for _, token := range local {
+		patterns = append(patterns, shellQuote(token))
+	}
+	if len(patterns) > 0 {
+		b.WriteString("\t\t\tcase \"$cmdpath:$word\" in\n")
+		fmt.Fprintf(b, "\t\t\t%s) skip=1 ;;\n", strings.Join(patterns, "|"))
+		b.WriteString("\t\t\tesac\n")
+	}
+	b.WriteString("\t\t\tcontinue\n\t\t\t;;\n\t\tesac\n")
+	b.WriteString("\t\tcase \"$cmdpath:$word\" in\n")
+	for _, c := range cmds[1:] {
+		patterns := make([]string, len(c.names))
+		for n, name := range c.names {
+			patterns[n] = shellQuote(c.parent + ":" + name)
+		}
+		fmt.Fprintf(b, "\t\t%s) cmdpath=%s ;;\n", strings.Join(patterns, "|"), shellQuote(c.path))
+	}
+	b.WriteString("\t\tesac\n\tdone\n")
+}
+
+func bashCompletionScript(root *Command) string {
+	funcName := "_" + shellIdentifier(root.Name) + "_complete"
+	cmds := completionCommands(root)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# bash completion for %s\n\n", root.Name)
+	fmt.Fprintf(&b, "%s() {\n", funcName)
+	b.WriteString("\tlocal cur=\"${COMP_WORDS[COMP_CWORD]}\" cmdpath=\"\" word i skip=0\n")
+	b.WriteString("\tlocal -a commands options candidates\n")
+	b.WriteString("\tCOMPREPLY=()\n")
+	writeCommandPathLoop(&b, cmds, "COMP_WORDS", "1", "COMP_CWORD")
+	dynamic := hasDynamicCompletion(cmds)
+	if !dynamic {
+		b.WriteString("\n\t# Leave option values to the default completion behavior\n")
+		b.WriteString("\tif ((skip)) || [[ $cur == \"=\" ]]; then\n\t\treturn\n\tfi\n")
+	}
+	b.WriteString("\n")
+	b.WriteString("\tcase \"$cmdpath\" in\n")
+	for _, c := range cmds {
+		fmt.Fprintf(&b, "\t%s)\n", shellQuote(c.path))
+		if names := sortedSubCommandNames(c.Command); len(names) > 0 {
+			for n := range names {
+				names[n] = shellQuote(names[n])
+			}
+			fmt.Fprintf(&b, "\t\tcommands=(%s)\n", strings.Join(names, " "))
+		}
+		var words []string
+		for _, opt := range completionOptions(c.Command) {
+			for _, word := range optionCompletionWords(opt) {
+				words = append(words, shellQuote(word))
+			}
+		}
+		fmt.Fprintf(&b, "\t\toptions=(%s)\n", strings.Join(words, " "))
+		b.WriteString("\t\t;;\n")
+	}
+	b.WriteString("\tesac\n\n")
+	if dynamic {
+		// Words are re-joined around "=" before passing them to the program, and
+		// the portion of the current token before the cursor's word is stripped
+		// from each candidate, since bash treats "=" as a word break
+		b.WriteString("\t# Obtain option values and arg values from the program itself\n")
+		b.WriteString("\tif ((skip)) || [[ $cur == \"=\" || ( $cur != -* && ${#commands[@]} -eq 0 ) ]]; then\n")
+		b.WriteString("\t\tlocal -a args\n\t\tlocal prefix\n")
+		b.WriteString("\t\tfor ((i = 1; i <= COMP_CWORD; i++)); do\n\t\t\tword=\"${COMP_WORDS[i]}\"\n")
+		b.WriteString("\t\t\tif ((i > 1)) && [[ $word == \"=\" || ${COMP_WORDS[i-1]} == \"=\" ]]; then\n")
+		b.WriteString("\t\t\t\targs[${#args[@]}-1]+=\"$word\"\n\t\t\telse\n\t\t\t\targs+=(\"$word\")\n\t\t\tfi\n\t\tdone\n")
+		b.WriteString("\t\tprefix=\"${args[${#args[@]}-1]}\"\n\t\tprefix=\"${prefix%\"$cur\"}\"\n")
+		fmt.Fprintf(&b, "\t\twhile IFS= read -r word; do\n\t\t\tCOMPREPLY+=(\"${word#\"$prefix\"}\")\n\t\tdone < <(\"${COMP_WORDS[0]}\" %s \"${args[@]}\" 2>/dev/null)\n", completeArg)
+		b.WriteString("\t\treturn\n\tfi\n\n")
+	}
+	b.WriteString("\tif [[ $cur == -* ]]; then\n\t\tcandidates=(\"${options[@]}\")\n\telse\n\t\tcandidates=(\"${commands[@]}\")\n\tfi\n")
+	b.WriteString("\tfor word in \"${candidates[@]}\"; do\n\t\t[[ $word == \"$cur\"* ]] && COMPREPLY+=(\"$word\")\n\tdone\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "complete -o default -F %s %s\n", funcName, shellQuote(root.Name))
+	return b.String()
+}
+
+func zshCompletionScript(root *Command) string {
+	funcName := "_" + shellIdentifier(root.Name)
+	cmds := completionCommands(root)
+	describe := func(name, desc string) string {
+		return shellQuote(strings.ReplaceAll(name, ":", `\:`) + ":" + completionDescription(desc))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "#compdef %s\n\n", root.Name)
+	fmt.Fprintf(&b, "%s() {\n", funcName)
+	b.WriteString("\tlocal cmdpath=\"\" word i skip=0\n")
+	b.WriteString("\tlocal -a commands options\n")
+	writeCommandPathLoop(&b, cmds, "words", "2", "CURRENT")
+	dynamic := hasDynamicCompletion(cmds)
+	if !dynamic {
+		b.WriteString("\n\t# Leave option values to the default completion behavior\n")
+		b.WriteString("\tif ((skip)) || [[ $PREFIX == --*=* ]]; then\n\t\t_default\n\t\treturn\n\tfi\n")
+	}
+	b.WriteString("\n")
+	b.WriteString("\tcase \"$cmdpath\" in\n")
+	for _, c := range cmds {
+		fmt.Fprintf(&b, "\t%s)\n", shellQuote(c.path))
+		if names := sortedSubCommandNames(c.Command); len(names) > 0 {
+			b.WriteString("\t\tcommands=(\n")
+			for _, name := range names {
+				fmt.Fprintf(&b, "\t\t\t%s\n", describe(name, c.SubCommands[name].Summary))
+			}
+			b.WriteString("\t\t)\n")
+		}
+		b.WriteString("\t\toptions=(\n")
+		for _, opt := range completionOptions(c.Command) {
+			for _, word := range optionCompletionWords(opt) {
+				fmt.Fprintf(&b, "\t\t\t%s\n", describe(word, opt.Description))
+			}
+		}
+		b.WriteString("\t\t)\n\t\t;;\n")
+	}
+	b.WriteString("\tesac\n\n")
+	if dynamic {
+		b.WriteString("\t# Obtain option values and arg values from the program itself\n")
+		b.WriteString("\tif ((skip)) || [[ $PREFIX == --*=* || ( $PREFIX != -* && ${#commands} -eq 0 ) ]]; then\n")
+		b.WriteString("\t\tlocal -a candidates\n")
+		fmt.Fprintf(&b, "\t\tcandidates=(${(f)\"$(\"${words[1]}\" %s \"${(@)words[2,CURRENT-1]}\" \"$PREFIX\" 2>/dev/null)\"})\n", completeArg)
+		b.WriteString("\t\tif ((${#candidates})); then\n\t\t\tcompadd -a candidates\n\t\telse\n\t\t\t_default\n\t\tfi\n")
+		b.WriteString("\t\treturn\n\tfi\n\n")
+	}
+	b.WriteString("\tif [[ $PREFIX == -* ]]; then\n\t\t_describe -t options 'option' options\n")
+	b.WriteString("\telif ((${#commands})); then\n\t\t_describe -t commands 'command' commands\n")
+	b.WriteString("\telse\n\t\t_default\n\tfi\n")
+	b.WriteString("}\n\n")
+	fmt.Fprintf(&b, "if [[ \"${funcstack[1]}\" == %s ]]; then\n\t%s \"$@\"\nelse\n\tcompdef %s %s\nfi\n", funcName, funcName, funcName, shellQuote(root.Name))
+	return b.String()
+}
+
+func fishCompletionScript(root *Command) string {
+	prefix := "__" + shellIdentifier(root.Name)
+	cmds := completionCommands(root)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# fish completion for %s\n\n", root.Name)
+
+	// Function for determining the current command path, from the tokens
+	// preceding the cursor
+	fmt.Fprintf(&b, "function %s_cmdpath\n", prefix)
+	b.WriteString("\tset -l tokens (commandline -opc)\n\tset -e tokens[1]\n")
+	b.WriteString("\tset -l cmdpath ''\n\tset -l skip 0\n")
+	b.WriteString("\tfor word in $tokens\n")
+	b.WriteString("\t\tif test $skip -eq 1\n\t\t\tset skip 0\n\t\t\tcontinue\n\t\tend\n")
+	b.WriteString("\t\tif string match -q -- '-*' $word\n")
+	global, local := valueOptionTokens(cmds)
+	for n := range global {
+		global[n] = fishQuote(global[n])
+	}
+	for n := range local {
+		local[n] = fishQuote(local[n])
+	}
+	if len(global) > 0 {
+		fmt.Fprintf(&b, "\t\t\tif contains -- $word %s\n\t\t\t\tset skip 1\n\t\t\tend\n", strings.Join(global, " "))
+	}
+	if len(local) > 0 {
+		fmt.Fprintf(&b, "\t\t\tif contains -- \"$cmdpath:$word\" %s\n\t\t\t\tset skip 1\n\t\t\tend\n", strings.Join(local, " "))
+	}
+	b.WriteString("\t\t\tcontinue\n\t\tend\n")
+	for n, c := range cmds[1:] {
+		keyword := "else if"
+		if n == 0 {
+			keyword = "if"
+		}
+		patterns := make([]string, len(c.names))
+		for n, name := range c.names {
+			patterns[n] = fishQuote(c.parent + ":" + name)
+		}
+		fmt.Fprintf(&b, "\t\t%s contains -- \"$cmdpath:$word\" %s\n\t\t\tset cmdpath %s\n", keyword, strings.Join(patterns, " "), fishQuote(c.path))
+	}
+	if len(cmds) > 1 {
+		b.WriteString("\t\tend\n")
+	}
+	b.WriteString("\tend\n\techo $cmdpath\nend\n\n")
+
+	// Function for checking whether the current command path is one of the
+	// supplied args
+	fmt.Fprintf(&b, "function %s_using\n\tcontains -- (%s_cmdpath) $argv\nend\n\n", prefix, prefix)
+
+	condition := func(paths []string) string {
+		quoted := make([]string, len(paths))
+		for n, path := range paths {
+			quoted[n] = fishQuote(path)
+		}
+		return fishQuote(prefix + "_using " + strings.Join(quoted, " "))
+	}
+
+	// Function for obtaining option values and arg values from the program
+	// itself. Any "--name=" prefix is stripped from candidates, since fish
+	// handles this automatically when completing option values.
+	dynamic := hasDynamicCompletion(cmds)
+	if dynamic {
+		fmt.Fprintf(&b, "function %s_complete\n", prefix)
+		b.WriteString("\tset -l tokens (commandline -opc)\n\tset -l cmd $tokens[1]\n\tset -e tokens[1]\n")
+		fmt.Fprintf(&b, "\t$cmd %s $tokens (commandline -ct) 2>/dev/null | string replace -r -- '^--[^=]*=' ''\nend\n\n", completeArg)
+	}
+	dynamicArgs := fishQuote("(" + prefix + "_complete)")
+
+	// Subcommands, or dynamic completion of positional args
+	for _, c := range cmds {
+		if dynamic && len(c.SubCommands) == 0 && slices.ContainsFunc(c.args, hasCompleter) {
+			fmt.Fprintf(&b, "complete -c %s -n %s -a %s\n", fishQuote(root.Name), condition([]string{c.path}), dynamicArgs)
+		}
+		for _, name := range sortedSubCommandNames(c.Command) {
+			fmt.Fprintf(&b, "complete -c %s -f -n %s -a %s -d %s\n", fishQuote(root.Name), condition([]string{c.path}), fishQuote(name), fishQuote(completionDescription(c.SubCommands[name].Summary)))
+		}
+	}
+
+	// Options. Identical options are typically available in many subcommands, so
+	// these are grouped to avoid excessive repetition.
+	var specs []string
+	specPaths := make(map[string][]string)
+	addSpec := func(spec, path string) {
+		if specPaths[spec] == nil {
+			specs = append(specs, spec)
+		}
+		specPaths[spec] = append(specPaths[spec], path)
+	}
+	for _, c := range cmds {
+		for _, opt := range completionOptions(c.Command) {
+			desc := fishQuote(completionDescription(opt.Description))
+			spec := "-l " + fishQuote(opt.Name)
+			if opt.Shorthand != 0 {
+				spec += " -s " + fishQuote(string(opt.Shorthand))
+			}
+			if opt.RequireValue {
+				spec += " -r"
+			}
+			if hasCompleter(opt) {
+				spec += " -a " + dynamicArgs
+			}
+			addSpec(spec+" -d "+desc, c.path)
+			if opt.Type == OptionTypeBool {
+				addSpec("-l "+fishQuote("skip-"+opt.Name)+" -d "+desc, c.path)
+			}
+		}
+	}
+	for _, spec := range specs {
+		if len(specPaths[spec]) < len(cmds) {
+			spec = "-n " + condition(specPaths[spec]) + " " + spec
+		}
+		fmt.Fprintf(&b, "complete -c %s %s\n", fishQuote(root.Name), spec)
+	}
+	return b.String()
+}
@@ -0,0 +1,209 @@
+package mybase
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCompletionScript(t *testing.T) {
+	suite := simpleCommandSuite()
+	suite.AddOption(StringOption("host", 'h', "", "dummy description").ValueRequired())
+	suite.AddOption(StringOption("old", 0, "", "dummy description").MarkDeprecated("details"))
+	suite.SubCommands["one"].AddAlias("uno")
+	suite.AddCompletionCommand()
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		script, err := suite.SubCommands["two"].CompletionScript(shell)
+		if err != nil {
+			t.Fatalf("Unexpected error from CompletionScript(%q): %v", shell, err)
+		}
+		for _, expected := range []string{"completion", "version", "newopt", "skip-bool1", "truthybool", "host", "uno"} {
+			if !strings.Contains(script, expected) {
+				t.Errorf("Expected %s completion script to contain %q, but it did not", shell, expected)
+			}
+		}
+		for _, unexpected := range []string{"skip-visible", "skip-hasshort", "'--old:", "-l 'old'"} {
+			if strings.Contains(script, unexpected) {
+				t.Errorf("Expected %s completion script to not contain %q, but it did", shell, unexpected)
+			}
+		}
+	}
+	for _, opt := range completionOptions(suite) {
+		if opt.HiddenOnCLI || opt.Deprecated() {
+			t.Errorf("Unexpected hidden or deprecated option %s returned by completionOptions", opt.Name)
+		}
+	}
+	if opts := completionOptions(suite.SubCommands["one"]); len(opts) != len(completionOptions(suite))+2 {
+		t.Errorf("Expected subcommand to have 2 more completion options than its parent, instead found %d vs %d", len(opts), len(completionOptions(suite)))
+	}
+	if _, err := suite.CompletionScript("powershell"); err == nil {
+		t.Error("Expected error from CompletionScript with unsupported shell, but err was nil")
+	}
+
+	// Confirm the completion command is wired up properly
+	cfg := ParseFakeCLI(t, suite, "mycommand completion zsh")
+	if cfg.CLI.Command.Name != "completion" || cfg.Get("shell") != "zsh" {
+		t.Errorf("Unexpected command %s or shell %q", cfg.CLI.Command.Name, cfg.Get("shell"))
+	}
+	cfg = ParseFakeCLI(t, suite, "mycommand completion powershell")
+	if err := cfg.HandleCommand(); err == nil {
+		t.Error("Expected error from completion command with unsupported shell, but err was nil")
+	}
+}
+
+// TestBashCompletionScript exercises the generated bash completion script,
+// if bash is available.
+func TestBashCompletionScript(t *testing.T) {
+	bashPath, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+	suite := simpleCommandSuite()
+	suite.AddOption(StringOption("host", 'h', "", "dummy description").ValueRequired())
+	suite.SubCommands["one"].AddAlias("uno")
+	script, _ := suite.CompletionScript("bash")
+	scriptPath := filepath.Join(t.TempDir(), "completion.bash")
+	if err := os.WriteFile(scriptPath, []byte(script), 0666); err != nil {
+		t.Fatalf("Unexpected error writing script: %v", err)
+	}
+
+	cases := map[string]string{
+		"mycommand ''":                      "help one two version",
+		"mycommand o":                       "one",
+		"mycommand --host one ''":           "help one two version",
+		"mycommand --host = one t":          "two",
+		"mycommand -h one ''":               "help one two version",
+		"mycommand uno --ne":                "--newopt",
+		"mycommand one --newopt two --vis":  "--visible",
+		"mycommand two --b":                 "--bool1 --bool2",
+		"mycommand --skip-t":                "--skip-truthybool",
+		"mycommand --host ''":               "",
+		"mycommand --visible = ''":          "",
+		"mycommand help --hidden = foo --h": "--hasshort --help --host",
+	}
+	for words, expected := range cases {
+		shellCode := "source " + shellQuote(scriptPath) + "\n" +
+			"COMP_WORDS=(" + words + ")\n" +
+			"COMP_CWORD=$((${#COMP_WORDS[@]} - 1))\n" +
+			"_mycommand_complete\n" +
+			`echo "${COMPREPLY[*]}"`
+		output, err := exec.Command(bashPath, "-c", shellCode).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unexpected error running completion script: %v\n%s", err, output)
+		}
+		if actual := strings.TrimSpace(string(output)); actual != expected {
+			t.Errorf("Unexpected completions for %q: expected %q, found %q", words, expected, actual)
+		}
+	}
+}
+
+// TestBashDynamicCompletion exercises the generated bash completion script's
+// logic for invoking the program to obtain completion candidates, if bash is
+// available. The program is replaced with a shell function which echoes its
+// final arg, followed by all of its args delimited by pipes.
+func TestBashDynamicCompletion(t *testing.T) {
+	bashPath, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash not available")
+	}
+	cmd := NewCommand("mycommand", "1.0", "description", nil)
+	cmd.AddOption(StringOption("host", 'h', "", "dummy description").CompleteWith(func(string, *Config) []string { return nil }))
+	cmd.AddArg("environment", "", false)
+	script, _ := cmd.CompletionScript("bash")
+	scriptPath := filepath.Join(t.TempDir(), "completion.bash")
+	if err := os.WriteFile(scriptPath, []byte(script), 0666); err != nil {
+		t.Fatalf("Unexpected error writing script: %v", err)
+	}
+
+	cases := map[string]string{
+		"mycommand ''":                  "__complete|",
+		"mycommand pro":                 "pro__complete|pro",
+		"mycommand --host ''":           "__complete|--host|",
+		"mycommand --host = ''":         "__complete|--host=",
+		"mycommand --host = db":         "db__complete|--host=db",
+		"mycommand -h db prod":          "prod__complete|-h|db|prod",
+		"mycommand --ho":                "--host",
+		"mycommand --version --host ''": "__complete|--version|--host|",
+	}
+	for words, expected := range cases {
+		shellCode := "source " + shellQuote(scriptPath) + "\n" +
+			"mycommand() { printf '%s' \"${@: -1}\"; (IFS='|'; echo \"$*\"); }\n" +
+			"COMP_WORDS=(" + words + ")\n" +
+			"COMP_CWORD=$((${#COMP_WORDS[@]} - 1))\n" +
+			"_mycommand_complete\n" +
+			`echo "${COMPREPLY[*]}"`
+		output, err := exec.Command(bashPath, "-c", shellCode).CombinedOutput()
+		if err != nil {
+			t.Fatalf("Unexpected error running completion script: %v\n%s", err, output)
+		}
+		if actual := strings.TrimSpace(string(output)); actual != expected {
+			t.Errorf("Unexpected completions for %q: expected %q, found %q", words, expected, actual)
+		}
+	}
+}
+
+func TestCompletionCandidates(t *testing.T) {
+	suite := simpleCommandSuite()
+	suite.AddOption(StringOption("host", 'h', "", "dummy description").CompleteWith(func(partial string, cfg *Config) []string {
+		return []string{"db1", "db2", "other"}
+	}))
+	suite.AddOption(StringOption("port", 'P', "", "dummy description"))
+	cmd := NewCommand("three", "summary", "description", nil)
+	cmd.AddArg("environment", "", true)
+	cmd.AddVariadicArg("files", 0)
+	cmd.CompleteArgWith("environment", func(partial string, cfg *Config) []string {
+		// Candidates may depend on other values in cfg
+		return []string{cfg.Get("visible") + "-production", cfg.Get("visible") + "-staging"}
+	})
+	cmd.CompleteArgWith("files", func(partial string, cfg *Config) []string {
+		return []string{"a.sql", "b.sql"}
+	})
+	suite.AddSubCommand(cmd)
+	suite.AddCompletionCommand()
+
+	cases := map[string][]string{
+		"mycommand __complete ''":                              {"completion", "help", "one", "three", "two", "version"},
+		"mycommand __complete t":                               {"three", "two"},
+		"mycommand __complete --host ''":                       {"db1", "db2", "other"},
+		"mycommand __complete --host d":                        {"db1", "db2"},
+		"mycommand __complete -bh d":                           {"db1", "db2"},
+		"mycommand __complete --host=d":                        {"--host=db1", "--host=db2"},
+		"mycommand __complete --port ''":                       nil,
+		"mycommand __complete --port=4 --doesnt-exist --tr":    {"--truthybool"},
+		"mycommand __complete --skip-tr":                       {"--skip-truthybool"},
+		"mycommand __complete three --visible=foo ''":          {"foo-production", "foo-staging"},
+		"mycommand __complete three --visible foo f":           {"foo-production", "foo-staging"},
+		"mycommand __complete three prod --visible=foo ''":     {"a.sql", "b.sql"},
+		"mycommand __complete three prod a.sql b.sql b":        {"b.sql"},
+		"mycommand __complete three prod -- --h":               nil,
+		"mycommand __complete three --bool1 --host db1 -- --h": nil,
+		"mycommand __complete two extra extra ''":              nil,
+		"mycommand __complete doesnt-exist ''":                 {"completion", "help", "one", "three", "two", "version"},
+		"mycommand __complete":                                 {"completion", "help", "one", "three", "two", "version"},
+		"mycommand __complete completion ''":                   {"bash", "fish", "zsh"},
+	}
+	for commandLine, expected := range cases {
+		// tokenizeCommandLine drops empty tokens, so re-add an empty partial token
+		args := tokenizeCommandLine(t, commandLine)
+		if strings.HasSuffix(commandLine, "''") {
+			args = append(args, "")
+		}
+		cfg, err := ParseCLI(suite, args)
+		if err != nil {
+			t.Errorf("Unexpected error from ParseCLI(%q): %v", commandLine, err)
+			continue
+		}
+		if actual := cfg.CLI.completion.candidates(cfg); !slices.Equal(actual, expected) {
+			t.Errorf("Unexpected candidates for %q: expected %q, found %q", commandLine, expected, actual)
+		}
+	}
+
+	// Without the hidden arg, normal parsing rules apply
+	if cfg, err := ParseCLI(suite, []string{"mycommand", "three", "--host"}); err == nil || cfg != nil {
+		t.Error("Expected error from ParseCLI, but err was nil")
+	}
+}
@@ -1,12 +1,23 @@
 package mybase
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"net"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
 )
 
 // OptionValuer should be implemented by anything that can parse and return
@@ -27,6 +38,26 @@ type DeprecationWarner interface {
 	DeprecationWarnings() []string
 }
 
+// SourceDescriber is an optional interface upgrade on OptionValuer, allowing a
+// source to describe where a specific option's value came from, in more detail
+// than the source's String method. For example, an EnvSource describes an
+// option's origin using the name of the corresponding environment variable.
+type SourceDescriber interface {
+	OptionValuer
+	DescribeSource(optionName string) string
+}
+
+// MultiOptionValuer is an optional interface upgrade on OptionValuer, for
+// sources which may supply multiple values for an option marked as Repeatable.
+// AllOptionValues should return every value for the option, in order from
+// lowest priority to highest priority, such that the last element is the same
+// value returned by OptionValue. Values are combined across sources by
+// Config.GetAll.
+type MultiOptionValuer interface {
+	OptionValuer
+	AllOptionValues(optionName string) (values []string, ok bool)
+}
+
 // StringMapValues is the most trivial possible implementation of the
 // OptionValuer interface: it just maps option name strings to option value
 // strings.
@@ -46,15 +77,49 @@ func (source StringMapValues) String() string {
 // Config represents a list of sources for option values -- the command-line
 // plus zero or more option files, or any other source implementing the
 // OptionValuer interface.
+// A Config is safe for concurrent use by multiple goroutines, including calls
+// to AddSource, SetRuntimeOverride, and Reload alongside option lookups.
+// However, the sources themselves (including CLI) must not be modified
+// directly once they are in use by the Config.
 type Config struct {
-	CLI              *CommandLine            // Parsed command-line
-	IsTest           bool                    // true if Config generated from test logic, false otherwise
-	LooseFileOptions bool                    // enable to ignore unknown options in all Files
-	runtimeOverrides StringMapValues         // Highest-priority option value overrides
-	sources          []OptionValuer          // Sources of option values, excluding CLI, Command, runtimeOverrides; higher indexes override lower indexes
-	unifiedValues    map[string]string       // Precomputed cache of option name => value
-	unifiedSources   map[string]OptionValuer // Precomputed cache of option name => which source supplied it
-	dirty            bool                    // true if source list has changed, meaning next access needs to recompute caches
+	CLI              *CommandLine                // Parsed command-line
+	IsTest           bool                        // true if Config generated from test logic, false otherwise
+	LooseFileOptions bool                        // enable to ignore unknown options in all Files
+	mu               sync.Mutex                  // Protects runtimeOverrides and sources, and serializes cache rebuilds
+	runtimeOverrides StringMapValues             // Highest-priority option value overrides; replaced rather than modified once in use by cache
+	sources          []OptionValuer              // Sources of option values, excluding CLI, Command, runtimeOverrides; higher indexes override lower indexes
+	cache            atomic.Pointer[configCache] // Precomputed option values and sources, or nil if next access needs to recompute
+}
+
+// configCache is an immutable snapshot of the option values and sources of a
+// Config. Lookups use the snapshot without locking; any change to the Config's
+// sources atomically replaces or clears it. Runtime overrides are layered on
+// top of the unified maps, so that SetRuntimeOverride can replace the snapshot
+// without copying them.
+type configCache struct {
+	unifiedValues  map[string]string       // option name => value, as of the most recent rebuild
+	unifiedSources map[string]OptionValuer // option name => which source supplied it, as of the most recent rebuild
+	overrides      StringMapValues         // runtime overrides, which take precedence over the unified maps
+}
+
+// value returns the value of the named option, and false if the option does
+// not exist.
+func (cache *configCache) value(name string) (string, bool) {
+	if value, ok := cache.overrides[name]; ok {
+		return value, true
+	}
+	value, ok := cache.unifiedValues[name]
+	return value, ok
+}
+
+// source returns the source which supplied the value of the named option, and
+// false if the option does not exist.
+func (cache *configCache) source(name string) (OptionValuer, bool) {
+	if _, ok := cache.overrides[name]; ok {
+		return cache.overrides, true
+	}
+	source, ok := cache.unifiedSources[name]
+	return source, ok
 }
 
 // NewConfig creates a Config object, given a CommandLine and any arbitrary
@@ -67,7 +132,6 @@ func NewConfig(cli *CommandLine, sources ...OptionValuer) *Config {
 		CLI:              cli,
 		runtimeOverrides: StringMapValues(make(map[string]string)),
 		sources:          sources,
-		dirty:            true,
 	}
 }
 
@@ -76,6 +140,8 @@ func NewConfig(cli *CommandLine, sources ...OptionValuer) *Config {
 // slice, meaning that a caller can add sources without impacting the original
 // Config's source list.
 func (cfg *Config) Clone() *Config {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
 	sourcesCopy := make([]OptionValuer, len(cfg.sources))
 	copy(sourcesCopy, cfg.sources)
 	runtimeOverridesCopy := StringMapValues(make(map[string]string, len(cfg.runtimeOverrides)))
@@ -88,7 +154,6 @@ func (cfg *Config) Clone() *Config {
 		LooseFileOptions: cfg.LooseFileOptions,
 		runtimeOverrides: runtimeOverridesCopy,
 		sources:          sourcesCopy,
-		dirty:            true,
 	}
 }
 
@@ -96,13 +161,78 @@ func (cfg *Config) Clone() *Config {
 // sources, with the exception of the CommandLine, which always takes
 // precedence.
 func (cfg *Config) AddSource(source OptionValuer) {
-	cfg.sources = append(cfg.sources, source)
-	cfg.dirty = true
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	// Intentionally allocates a new slice, since a previous slice may still be
+	// in use by a caller of Sources
+	cfg.sources = append(slices.Clip(cfg.sources), source)
+	cfg.cache.Store(nil)
+}
+
+// Reload re-reads and re-parses any *File sources of cfg which have changed on
+// disk since they were last read, as determined by the modification time,
+// size, and contents of each file, along with any files or directories that
+// it includes.
+// Each changed File is replaced in cfg's sources by a new *File, which uses
+// the same settings and selected sections as the original; these new Files
+// are returned. The original Files are not modified, so other Configs using
+// them (for example via Clone) are unaffected.
+// If any changed file cannot be read or parsed, an error is returned and cfg
+// is left unchanged, continuing to use the last successfully-loaded values
+// from all files.
+func (cfg *Config) Reload() (changed []*File, err error) {
+	changed, _, _, err = cfg.reload()
+	return changed, err
+}
+
+// reload implements Reload. If any files changed, it also returns cfg's cache
+// from immediately before and after replacing them. Both are obtained while
+// holding cfg's lock, so that any concurrent changes from other methods, such
+// as SetRuntimeOverride or AddSource, are not attributed to the reload.
+func (cfg *Config) reload() (changed []*File, before, after *configCache, err error) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	newSources := slices.Clone(cfg.sources)
+	for n, source := range cfg.sources {
+		f, ok := source.(*File)
+		if !ok || !f.parsed || !f.changedOnDisk() {
+			continue
+		}
+		nf, err := f.reloaded(cfg)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		newSources[n] = nf
+		changed = append(changed, nf)
+	}
+	if len(changed) > 0 {
+		if before = cfg.cache.Load(); before == nil {
+			before = cfg.rebuild()
+		}
+		cfg.sources = newSources
+		after = cfg.rebuild()
+		cfg.cache.Store(after)
+	}
+	return changed, before, after, nil
 }
 
 // HandleCommand executes the CommandHandler callback associated with the
-// Command that was parsed on the CommandLine.
+// Command that was parsed on the CommandLine. If the CommandLine was instead a
+// request for tab completion candidates, the candidates are printed, one per
+// line; see Option.CompleteWith for more information.
+// Prior to running the handler, option values are checked using Validate. If
+// any values are invalid, the handler is not run, and the validation errors are
+// returned, joined via errors.Join. Built-in help, version, completion, and
+// print-config handling intentionally skips validation.
 func (cfg *Config) HandleCommand() error {
+	// Handle requests for tab completion candidates from a shell completion script
+	if cfg.CLI.completion != nil {
+		for _, candidate := range cfg.CLI.completion.candidates(cfg) {
+			fmt.Println(candidate)
+		}
+		return nil
+	}
+
 	// Handle --help if supplied as an option instead of as a subcommand
 	// (Note that format "command help [<subcommand>]" is already parsed properly into help command)
 	if forCommandName, helpWanted := cfg.CLI.OptionValues["help"]; helpWanted {
@@ -118,12 +248,87 @@ func (cfg *Config) HandleCommand() error {
 		return versionHandler(cfg)
 	}
 
+	// Handle --print-config, if enabled via Command.AddPrintConfigOption. This
+	// intentionally occurs prior to validation, since the report may be useful
+	// in debugging invalid values.
+	if cfg.printConfigWanted() {
+		return cfg.Dump(os.Stdout)
+	}
+
+	if !cfg.CLI.Command.builtIn {
+		if errs := cfg.Validate(); len(errs) > 0 {
+			return errors.Join(errs...)
+		}
+	}
 	return cfg.CLI.Command.Handler(cfg)
 }
 
+// Validate checks the value of every option of the current command. Values of
+// options with a ValueType, as set by typed constructors such as IntOption or
+// EnumOption, must be valid for that type, although blank values are always
+// permitted. Additionally, any validation callbacks added via Option.Validate
+// or Option.WithValidator are run. An OptionValueError is returned for each
+// invalid value, in order by option name, describing which source supplied the
+// value: for example a file path and line number, or "command line". Default
+// values are not checked, since these are not user-supplied. If all values are
+// valid, the returned slice is empty.
+//
+// HandleCommand calls Validate automatically prior to running the command's
+// handler, so most programs do not need to call it directly.
+func (cfg *Config) Validate() []error {
+	cache := cfg.current()
+	options := cfg.CLI.Command.Options()
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		opt := options[name]
+		source, _ := cache.source(name)
+		validators := append(slices.Clip(opt.validators), opt.ingestValidators...)
+		if (opt.ValueType == ValueTypeAny && len(validators) == 0) || source == cfg.CLI.Command {
+			continue
+		}
+		rawValue, _ := cache.value(name)
+		value := unquote(rawValue)
+		if err := opt.checkValue(value); err != nil {
+			errs = append(errs, OptionValueError{
+				Name:     name,
+				Value:    value,
+				Source:   source,
+				Expected: opt.expectedValue(),
+				Err:      err,
+			})
+			continue
+		}
+		for _, validator := range validators {
+			if err := validator(value); err != nil {
+				errs = append(errs, OptionValueError{
+					Name:   name,
+					Value:  value,
+					Source: source,
+					Err:    err,
+				})
+				break
+			}
+		}
+	}
+	return errs
+}
+
 // Sources returns a slice of OptionValuer values used as option sources for
 // cfg. The result is ordered from lowest-priority to highest-priority.
 func (cfg *Config) Sources() []OptionValuer {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	return cfg.allSources()
+}
+
+// allSources returns the result for Sources. The caller must hold cfg.mu.
+func (cfg *Config) allSources() []OptionValuer {
 	allSources := make([]OptionValuer, 1, len(cfg.sources)+2)
 
 	// Lowest-priority source is the current command, which returns default values
@@ -141,37 +346,47 @@ func (cfg *Config) Sources() []OptionValuer {
 
 // rebuild iterates over all sources, to construct a single cached key-value
 // lookup map. This improves performance of subsequent option value lookups.
-func (cfg *Config) rebuild() {
+// The caller must hold cfg.mu.
+func (cfg *Config) rebuild() *configCache {
 	options := cfg.CLI.Command.Options()
-	cfg.unifiedValues = make(map[string]string, len(options)+len(cfg.CLI.Command.args))
-	cfg.unifiedSources = make(map[string]OptionValuer, len(options)+len(cfg.CLI.Command.args))
+	cache := &configCache{
+		unifiedValues:  make(map[string]string, len(options)+len(cfg.CLI.Command.args)),
+		unifiedSources: make(map[string]OptionValuer, len(options)+len(cfg.CLI.Command.args)),
+		overrides:      cfg.runtimeOverrides,
+	}
 
 	// Iterate over positional CLI args. These have highest precedence of all, and
 	// are treated as a special-case (not placed in sources and work differently
 	// than normal options, since they cannot appear in option files)
 	for pos, arg := range cfg.CLI.Command.args {
 		if pos < len(cfg.CLI.ArgValues) { // supplied on CLI
-			cfg.unifiedSources[arg.Name] = cfg.CLI
-			cfg.unifiedValues[arg.Name] = cfg.CLI.ArgValues[pos]
+			cache.unifiedSources[arg.Name] = cfg.CLI
+			if arg.Name == cfg.CLI.Command.variadicArgName() {
+				// Variadic arg values are joined with spaces; see GetArgs to obtain them
+				// as a slice instead
+				cache.unifiedValues[arg.Name] = strings.Join(cfg.CLI.ArgValues[pos:], " ")
+			} else {
+				cache.unifiedValues[arg.Name] = cfg.CLI.ArgValues[pos]
+			}
 			delete(options, arg.Name) // shadow any normal option that has same name
 		} else { // not supplied on CLI - using default value
 			// In this case we intentionally DON'T shadow any normal option with same
 			// name, since a supplied option should override an unsupplied arg default.
-			cfg.unifiedSources[arg.Name] = cfg.CLI.Command
-			cfg.unifiedValues[arg.Name] = arg.Default
+			cache.unifiedSources[arg.Name] = cfg.CLI.Command
+			cache.unifiedValues[arg.Name] = arg.Default
 		}
 	}
 
 	// Iterate over all options, and set them in our maps for tracking values and sources.
 	// We go in reverse order to start at highest priority and break early when a value is found.
-	allSources := cfg.Sources()
+	allSources := cfg.allSources()
 	for name := range options {
 		var found bool
 		for n := len(allSources) - 1; n >= 0 && !found; n-- {
 			source := allSources[n]
 			if value, ok := source.OptionValue(name); ok {
-				cfg.unifiedValues[name] = value
-				cfg.unifiedSources[name] = source
+				cache.unifiedValues[name] = value
+				cache.unifiedSources[name] = source
 				found = true
 			}
 		}
@@ -181,13 +396,23 @@ func (cfg *Config) rebuild() {
 		}
 	}
 
-	cfg.dirty = false
+	return cache
 }
 
-func (cfg *Config) rebuildIfDirty() {
-	if cfg.dirty {
-		cfg.rebuild()
+// current returns cfg's cache of option values and sources, first rebuilding
+// it if needed. Once the cache has been built, this does not require locking.
+func (cfg *Config) current() *configCache {
+	if cache := cfg.cache.Load(); cache != nil {
+		return cache
 	}
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cache := cfg.cache.Load() // another goroutine may have rebuilt it while we waited for the lock
+	if cache == nil {
+		cache = cfg.rebuild()
+		cfg.cache.Store(cache)
+	}
+	return cache
 }
 
 // MarkDirty causes the config to rebuild itself on next option lookup. This
@@ -197,7 +422,7 @@ func (cfg *Config) rebuildIfDirty() {
 // Deprecated: Callers should prefer using SetRuntimeOverride, instead of
 // directly manipulating a source and then calling MarkDirty.
 func (cfg *Config) MarkDirty() {
-	cfg.dirty = true
+	cfg.cache.Store(nil)
 }
 
 // SetRuntimeOverride sets an override value for the supplied option name.
@@ -205,23 +430,34 @@ func (cfg *Config) MarkDirty() {
 // were supplied on the CLI. The supplied name must correspond to a known option
 // in cfg, otherwise this method panics.
 func (cfg *Config) SetRuntimeOverride(name, value string) {
+	cfg.mu.Lock()
+	defer cfg.mu.Unlock()
+	cache := cfg.cache.Load()
 	var optionExists bool
-	if cfg.dirty {
+	if cache == nil {
 		optionExists = (cfg.FindOption(name) != nil)
 	} else {
-		_, optionExists = cfg.unifiedSources[name]
+		_, optionExists = cache.unifiedSources[name]
 	}
 	if !optionExists {
 		panic(fmt.Errorf("Assertion failed: option %s does not exist", name))
 	}
 
-	cfg.runtimeOverrides[name] = value
-	if !cfg.dirty {
-		// Instead of marking the config as dirty and rebuilding it lazily, we can
-		// just set the value right away, since runtime overrides are always the
-		// highest priority source.
-		cfg.unifiedValues[name] = value
-		cfg.unifiedSources[name] = cfg.runtimeOverrides
+	// Other goroutines may be reading the existing overrides map via the cache,
+	// so it is replaced rather than modified in-place
+	overrides := maps.Clone(cfg.runtimeOverrides)
+	overrides[name] = value
+	cfg.runtimeOverrides = overrides
+	if cache != nil {
+		// Instead of clearing the cache and rebuilding it lazily, we can just
+		// replace its overrides layer, since runtime overrides are always the
+		// highest priority source. The unified maps are shared with the previous
+		// snapshot, since they are never modified once stored.
+		cfg.cache.Store(&configCache{
+			unifiedValues:  cache.unifiedValues,
+			unifiedSources: cache.unifiedSources,
+			overrides:      overrides,
+		})
 	}
 }
 
@@ -234,7 +470,7 @@ func (cfg *Config) Changed(name string) bool {
 	opt := cfg.FindOption(name)
 	// Note that opt cannot be nil here, so no need to check. If the name didn't
 	// correspond to an existing option, the previous call to Supplied panics.
-	return (unquote(cfg.unifiedValues[name]) != opt.Default)
+	return (unquote(cfg.GetRaw(name)) != opt.Default)
 }
 
 // Supplied returns true if the specified option name has been set by some
@@ -288,14 +524,37 @@ func (cfg *Config) OnCLI(name string) bool {
 // Source returns the OptionValuer that provided the specified option. If the
 // option does not exist, panics to indicate programmer error.
 func (cfg *Config) Source(name string) OptionValuer {
-	cfg.rebuildIfDirty()
-	source, ok := cfg.unifiedSources[name]
+	source, ok := cfg.current().source(name)
 	if !ok {
 		panic(fmt.Errorf("Assertion failed: option %s does not exist", name))
 	}
 	return source
 }
 
+// DescribeSource returns a human-readable description of the source that
+// provided the specified option, such as "command line" or "environment
+// variable FOO_HOST". If the option does not exist, panics to indicate
+// programmer error.
+func (cfg *Config) DescribeSource(name string) string {
+	return describeSource(cfg.Source(name), name)
+}
+
+// describeSource returns a description of source's value for optionName. If
+// source implements SourceDescriber, its description is used; otherwise the
+// source's String method is used if available.
+func describeSource(source OptionValuer, optionName string) string {
+	switch source := source.(type) {
+	case SourceDescriber:
+		return source.DescribeSource(optionName)
+	case *Command:
+		return "default value"
+	case fmt.Stringer:
+		return source.String()
+	default:
+		return fmt.Sprintf("%T", source)
+	}
+}
+
 // FindOption returns an Option by name. It first searches the current command
 // hierarchy, but if it fails to find the option there, it then searches all
 // other command hierarchies as well. This makes it suitable for use in parsing
@@ -339,7 +598,10 @@ func (cfg *Config) FindOption(name string) *Option {
 // which specifies an option that has been marked as deprecated.
 func (cfg *Config) DeprecatedOptionUsage() []string {
 	warnings := cfg.CLI.DeprecationWarnings()
-	for _, src := range cfg.sources {
+	cfg.mu.Lock()
+	sources := cfg.sources
+	cfg.mu.Unlock()
+	for _, src := range sources {
 		if warner, ok := src.(DeprecationWarner); ok {
 			warnings = append(warnings, warner.DeprecationWarnings()...)
 		}
@@ -351,8 +613,7 @@ func (cfg *Config) DeprecatedOptionUsage() []string {
 // its default value will be returned. Panics if the option does not exist,
 // since this is indicative of programmer error, not runtime error.
 func (cfg *Config) GetRaw(name string) string {
-	cfg.rebuildIfDirty()
-	value, ok := cfg.unifiedValues[name]
+	value, ok := cfg.current().value(name)
 	if !ok {
 		panic(fmt.Errorf("Assertion failed: called Get on unknown option %s", name))
 	}
@@ -435,11 +696,25 @@ func (cfg *Config) GetSliceAllowEnvVar(name string, delimiter rune, unwrapFullVa
 
 func splitValueIntoSlice(value string, delimiter rune) []string {
 	tokens := []string{}
-	var startToken int
+	for value != "" {
+		token, rest, _ := cutUnquoted(value, delimiter)
+		if token = strings.TrimSpace(unquote(token)); token != "" {
+			tokens = append(tokens, token)
+		}
+		value = rest
+	}
+	return tokens
+}
+
+// cutUnquoted slices value around the first instance of delimiter which is not
+// inside of a quoted substring and not escaped with a backslash, returning the
+// text before and after the delimiter. If no such delimiter is present, it
+// returns value, "", false.
+func cutUnquoted(value string, delimiter rune) (before, after string, found bool) {
 	var inQuote rune
 	var escapeNext bool
-	for n, c := range value + string(delimiter) {
-		if escapeNext && n < len(value) {
+	for n, c := range value {
+		if escapeNext {
 			escapeNext = false
 			continue
 		}
@@ -447,12 +722,8 @@ func splitValueIntoSlice(value string, delimiter rune) []string {
 		case '\\':
 			escapeNext = true
 		case delimiter:
-			if inQuote == 0 || n == len(value) {
-				token := strings.TrimSpace(unquote(value[startToken:n]))
-				if token != "" {
-					tokens = append(tokens, token)
-				}
-				startToken = n + 1
+			if inQuote == 0 {
+				return value[:n], value[n+utf8.RuneLen(c):], true
 			}
 		case '\'', '"', '`':
 			if inQuote > 0 {
@@ -462,7 +733,98 @@ func splitValueIntoSlice(value string, delimiter rune) []string {
 			}
 		}
 	}
-	return tokens
+	return value, "", false
+}
+
+// GetMap returns an option's value as a map of string keys to string values.
+// The value is split into key-value pairs using pairDelim, and then each pair
+// is split into a key and value at the first occurrence of kvDelim. Quoting
+// and escaping rules are the same as GetSlice: delimiters contained inside
+// quoted values have no effect, nor do backslash-escaped delimiters, and
+// quote-wrapped keys or values have their surrounding quotes stripped. Leading
+// and trailing whitespace around keys and values is stripped. If a key
+// appears multiple times, its last value is used. If any pair lacks kvDelim or
+// has an empty key, an error is returned in the form of an OptionValueError.
+// Panics if the option does not exist.
+func (cfg *Config) GetMap(name string, pairDelim, kvDelim rune) (map[string]string, error) {
+	value := cfg.Get(name)
+	result, err := parseMap(value, pairDelim, kvDelim)
+	if err != nil {
+		return nil, cfg.valueError(name, value, expectedMapValue(pairDelim, kvDelim), err)
+	}
+	return result, nil
+}
+
+// parseMap implements the parsing logic of GetMap.
+func parseMap(value string, pairDelim, kvDelim rune) (map[string]string, error) {
+	result := make(map[string]string)
+	for _, pair := range splitValueIntoSlice(value, pairDelim) {
+		key, value, found := cutUnquoted(pair, kvDelim)
+		if key = unquote(key); !found || key == "" {
+			return nil, fmt.Errorf("invalid key-value pair %q", pair)
+		}
+		result[key] = unquote(value)
+	}
+	return result, nil
+}
+
+// GetAll returns all values of an option marked as Repeatable, combined across
+// all sources, in order from lowest priority to highest priority, as per
+// Sources. Within a single source, values are ordered as they were supplied.
+// Values are unquoted as per Get. An empty value, such as from "--skip-name"
+// or "--name=" on the command-line, discards all values from lower-priority
+// sources as well as earlier values in the same source. The option's default
+// value is only included if no source supplies a value. For options which are
+// not repeatable, each source supplies at most one value. Panics if the option
+// does not exist.
+func (cfg *Config) GetAll(name string) []string {
+	if _, ok := cfg.current().unifiedValues[name]; !ok {
+		panic(fmt.Errorf("Assertion failed: called GetAll on unknown option %s", name))
+	}
+	values := []string{}
+	var supplied bool
+	for _, source := range cfg.Sources() {
+		if source == cfg.CLI.Command {
+			continue
+		}
+		var sourceValues []string
+		if multi, ok := source.(MultiOptionValuer); ok {
+			sourceValues, _ = multi.AllOptionValues(name)
+		} else if value, ok := source.OptionValue(name); ok {
+			sourceValues = []string{value}
+		}
+		for _, value := range sourceValues {
+			supplied = true
+			if value = unquote(value); value == "" {
+				values = values[:0]
+			} else {
+				values = append(values, value)
+			}
+		}
+	}
+	if !supplied {
+		if defaultValue, _ := cfg.CLI.Command.OptionValue(name); unquote(defaultValue) != "" {
+			values = append(values, unquote(defaultValue))
+		}
+	}
+	return values
+}
+
+// GetArgs returns the values supplied on the command-line for the current
+// command's variadic positional arg, as added by Command.AddVariadicArg. As
+// with Get, quote-wrapped values are unquoted. If no values were supplied, an
+// empty slice is returned. Panics if name does not refer to the current
+// command's variadic arg, since this is indicative of programmer error.
+func (cfg *Config) GetArgs(name string) []string {
+	cmd := cfg.CLI.Command
+	if name == "" || cmd.variadicArgName() != name {
+		panic(fmt.Errorf("Assertion failed: command %s does not have variadic arg %s", cmd.Name, name))
+	}
+	values := []string{}
+	for pos := len(cmd.args) - 1; pos < len(cfg.CLI.ArgValues); pos++ {
+		values = append(values, unquote(cfg.CLI.ArgValues[pos]))
+	}
+	return values
 }
 
 // GetBool returns an option's value as a bool. If the option is not set, its
@@ -471,56 +833,115 @@ func (cfg *Config) GetBool(name string) bool {
 	return BoolValue(cfg.Get(name))
 }
 
+// GetCount returns an option's value as a non-negative count. This is intended
+// for use with options created by CountOption, but may be used with any
+// boolean option. Values which cannot be interpreted as a count are treated as
+// booleans, with any truthy value being a count of 1. Panics if the option does
+// not exist.
+func (cfg *Config) GetCount(name string) int {
+	value := cfg.Get(name)
+	if n, ok := countValue(value); ok {
+		return n
+	} else if BoolValue(value) {
+		return 1
+	}
+	return 0
+}
+
 // GetInt returns an option's value as an int. If an error occurs in parsing
-// the value as an int, it is returned as the second return value. Panics if
-// the option does not exist.
+// the value as an int, it is returned as the second return value, in the form
+// of an OptionValueError. Panics if the option does not exist.
 func (cfg *Config) GetInt(name string) (int, error) {
-	return strconv.Atoi(cfg.Get(name))
+	value := cfg.Get(name)
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, cfg.valueError(name, value, expectedValueOfType(ValueTypeInt), err)
+	}
+	return intValue, nil
 }
 
 // GetIntOrDefault is like GetInt, but returns the option's default value if
 // parsing the supplied value as an int fails. Panics if the option does not
 // exist.
 func (cfg *Config) GetIntOrDefault(name string) int {
-	value, err := cfg.GetInt(name)
+	return getOrDefault(cfg, name, "int", cfg.GetInt, strconv.Atoi)
+}
+
+// getOrDefault implements the logic of the various Get*OrDefault methods:
+// getter is called on the option, and if it returns an error, the option's
+// default value is parsed using parse instead. Panics if parsing the default
+// value fails, since this is indicative of programmer error. typeName is only
+// used in the panic message.
+func getOrDefault[T any](cfg *Config, name, typeName string, getter func(string) (T, error), parse func(string) (T, error)) T {
+	value, err := getter(name)
 	if err != nil {
 		defaultValue, _ := cfg.CLI.Command.OptionValue(name)
-		value, err = strconv.Atoi(defaultValue)
+		value, err = parse(defaultValue)
 		if err != nil {
-			panic(fmt.Errorf("Assertion failed: default value for option %s is %s, which fails int parsing", name, defaultValue))
+			panic(fmt.Errorf("Assertion failed: default value for option %s is %s, which fails %s parsing", name, defaultValue, typeName))
 		}
 	}
 	return value
 }
 
+// GetFloat returns an option's value as a float64. If an error occurs in
+// parsing the value, it is returned as the second return value, in the form of
+// an OptionValueError. Panics if the option does not exist.
+func (cfg *Config) GetFloat(name string) (float64, error) {
+	value := cfg.Get(name)
+	floatValue, err := parseFloat(value)
+	if err != nil {
+		return 0, cfg.valueError(name, value, expectedValueOfType(ValueTypeFloat), err)
+	}
+	return floatValue, nil
+}
+
+// GetFloatOrDefault is like GetFloat, but returns the option's default value if
+// parsing the supplied value as a float64 fails. Panics if the option does not
+// exist.
+func (cfg *Config) GetFloatOrDefault(name string) float64 {
+	return getOrDefault(cfg, name, "float", cfg.GetFloat, parseFloat)
+}
+
+// parseFloat implements the parsing logic of GetFloat.
+func parseFloat(value string) (float64, error) {
+	return strconv.ParseFloat(value, 64)
+}
+
 // GetEnum returns an option's value as a string if it matches one of the
 // supplied allowed values, or its default value (which need not be supplied).
-// Otherwise an error is returned. Matching is case-insensitive, but the
-// returned value will always be of the same case as it was supplied in
-// allowedValues. Panics if the option does not exist.
+// Otherwise an OptionValueError is returned. Matching is case-insensitive, but
+// the returned value will always be of the same case as it was supplied in
+// allowedValues. If no allowed values are supplied, and the option was created
+// by EnumOption, the option's own allowed values are used. Panics if the option
+// does not exist.
 func (cfg *Config) GetEnum(name string, allowedValues ...string) (string, error) {
-	value := strings.ToLower(cfg.Get(name))
-	defaultValue, _ := cfg.CLI.Command.OptionValue(name)
-	var seenDefaultInAllowed bool
-	for _, allowedVal := range allowedValues {
-		if value == strings.ToLower(allowedVal) {
-			return allowedVal, nil
-		}
-		if strings.ToLower(allowedVal) == strings.ToLower(defaultValue) {
-			seenDefaultInAllowed = true
+	if len(allowedValues) == 0 {
+		if opt := cfg.FindOption(name); opt != nil {
+			allowedValues = opt.enumValues
 		}
 	}
-	if !seenDefaultInAllowed {
-		if value == strings.ToLower(defaultValue) {
-			return defaultValue, nil
+	defaultValue, _ := cfg.CLI.Command.OptionValue(name)
+	value := cfg.Get(name)
+	result, ok := enumValue(value, defaultValue, allowedValues)
+	if !ok {
+		return "", cfg.valueError(name, value, expectedEnumValue(defaultValue, allowedValues), nil)
+	}
+	return result, nil
+}
+
+// enumValue implements the logic of GetEnum, returning false if value is not
+// allowed.
+func enumValue(value, defaultValue string, allowedValues []string) (string, bool) {
+	for _, allowedVal := range allowedValues {
+		if strings.EqualFold(value, allowedVal) {
+			return allowedVal, true
 		}
-		allowedValues = append(allowedValues, defaultValue)
 	}
-	for n := range allowedValues {
-		allowedValues[n] = fmt.Sprintf(`"%s"`, allowedValues[n])
+	if strings.EqualFold(value, defaultValue) {
+		return defaultValue, true
 	}
-	allAllowed := strings.Join(allowedValues, ", ")
-	return "", fmt.Errorf("Option %s can only be set to one of these values: %s", name, allAllowed)
+	return "", false
 }
 
 // GetBytes returns an option's value as a uint64 representing a number of bytes.
@@ -529,11 +950,21 @@ func (cfg *Config) GetEnum(name string, allowedValues ...string) (string, error)
 // 1024^3 respectively. Suffixes may also be expressed with a trailing 'B',
 // e.g. 'KB' and 'K' are equivalent.
 // A blank string will be returned as 0, with no error. Aside from that case,
-// an error will be returned if the value cannot be parsed as a byte size.
-// Panics if the option does not exist.
+// an OptionValueError will be returned if the value cannot be parsed as a byte
+// size. Panics if the option does not exist.
 func (cfg *Config) GetBytes(name string) (uint64, error) {
+	value := cfg.Get(name)
+	bytes, err := parseBytes(value)
+	if err != nil {
+		return 0, cfg.valueError(name, value, expectedValueOfType(ValueTypeBytes), err)
+	}
+	return bytes, nil
+}
+
+// parseBytes implements the logic of GetBytes.
+func parseBytes(value string) (uint64, error) {
 	var multiplier uint64 = 1
-	value := strings.ToLower(cfg.Get(name))
+	value = strings.ToLower(value)
 	if value == "" {
 		return 0, nil
 	}
@@ -556,10 +987,44 @@ func (cfg *Config) GetBytes(name string) (uint64, error) {
 	return numVal * multiplier, err
 }
 
+// GetDuration returns an option's value as a time.Duration. The value may be
+// any string accepted by time.ParseDuration, such as "300ms" or "1h15m30s", or
+// alternatively a bare number, which is interpreted as a number of seconds, as
+// is typical for MySQL timeout settings.
+// A blank string will be returned as 0, with no error. Aside from that case,
+// an OptionValueError will be returned if the value cannot be parsed as a
+// duration. Panics if the option does not exist.
+func (cfg *Config) GetDuration(name string) (time.Duration, error) {
+	value := cfg.Get(name)
+	d, err := parseDuration(value)
+	if err != nil {
+		return 0, cfg.valueError(name, value, expectedValueOfType(ValueTypeDuration), err)
+	}
+	return d, nil
+}
+
+// GetDurationOrDefault is like GetDuration, but returns the option's default
+// value if parsing the supplied value as a duration fails. Panics if the option
+// does not exist.
+func (cfg *Config) GetDurationOrDefault(name string) time.Duration {
+	return getOrDefault(cfg, name, "duration", cfg.GetDuration, parseDuration)
+}
+
+// parseDuration implements the parsing logic of GetDuration.
+func parseDuration(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil && math.Abs(seconds) < math.MaxInt64/float64(time.Second) {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(value)
+}
+
 // GetRegexp returns an option's value as a compiled *regexp.Regexp. If the
 // option value isn't set (empty string), returns nil,nil. If the option value
 // is set but cannot be compiled as a valid regular expression, returns nil and
-// an error value. Panics if the named option does not exist.
+// an OptionValueError. Panics if the named option does not exist.
 func (cfg *Config) GetRegexp(name string) (*regexp.Regexp, error) {
 	value := cfg.Get(name)
 	if value == "" {
@@ -567,11 +1032,145 @@ func (cfg *Config) GetRegexp(name string) (*regexp.Regexp, error) {
 	}
 	re, err := regexp.Compile(value)
 	if err != nil {
-		return nil, fmt.Errorf("Invalid regexp for option %s: %s", name, value)
+		return nil, cfg.valueError(name, value, expectedValueOfType(ValueTypeRegexp), err)
 	}
 	return re, nil
 }
 
+// GetIP returns an option's value as a net.IP. The value may be an IPv4 or
+// IPv6 address. If the option value isn't set (empty string), returns nil,nil.
+// If the option value is set but cannot be parsed as an IP address, returns
+// nil and an OptionValueError. Panics if the named option does not exist.
+func (cfg *Config) GetIP(name string) (net.IP, error) {
+	value := cfg.Get(name)
+	ip, err := parseIP(value)
+	if err != nil {
+		return nil, cfg.valueError(name, value, "IP address", err)
+	}
+	return ip, nil
+}
+
+// GetIPOrDefault is like GetIP, but returns the option's default value if
+// parsing the supplied value as an IP address fails. Panics if the option does
+// not exist.
+func (cfg *Config) GetIPOrDefault(name string) net.IP {
+	return getOrDefault(cfg, name, "IP address", cfg.GetIP, parseIP)
+}
+
+// parseIP implements the parsing logic of GetIP.
+func parseIP(value string) (net.IP, error) {
+	if value == "" {
+		return nil, nil
+	}
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return nil, &net.ParseError{Type: "IP address", Text: value}
+	}
+	return ip, nil
+}
+
+// GetIPNet returns an option's value as a *net.IPNet, parsed from CIDR
+// notation such as "192.0.2.0/24" or "2001:db8::/32". If the option value
+// isn't set (empty string), returns nil,nil. If the option value is set but
+// cannot be parsed as a CIDR range, returns nil and an OptionValueError.
+// Panics if the named option does not exist.
+func (cfg *Config) GetIPNet(name string) (*net.IPNet, error) {
+	value := cfg.Get(name)
+	ipNet, err := parseIPNet(value)
+	if err != nil {
+		return nil, cfg.valueError(name, value, "CIDR range such as 10.0.0.0/8", err)
+	}
+	return ipNet, nil
+}
+
+// GetIPNetOrDefault is like GetIPNet, but returns the option's default value
+// if parsing the supplied value as a CIDR range fails. Panics if the option
+// does not exist.
+func (cfg *Config) GetIPNetOrDefault(name string) *net.IPNet {
+	return getOrDefault(cfg, name, "CIDR", cfg.GetIPNet, parseIPNet)
+}
+
+// parseIPNet implements the parsing logic of GetIPNet.
+func parseIPNet(value string) (*net.IPNet, error) {
+	if value == "" {
+		return nil, nil
+	}
+	_, ipNet, err := net.ParseCIDR(value)
+	return ipNet, err
+}
+
+// GetURL returns an option's value as a *url.URL. The URL must be absolute,
+// meaning it must have a scheme. If the option value isn't set (empty string),
+// returns nil,nil. If the option value is set but cannot be parsed as an
+// absolute URL, returns nil and an OptionValueError. Panics if the named
+// option does not exist.
+func (cfg *Config) GetURL(name string) (*url.URL, error) {
+	value := cfg.Get(name)
+	u, err := parseURL(value)
+	if err != nil {
+		return nil, cfg.valueError(name, value, "absolute URL", err)
+	}
+	return u, nil
+}
+
+// GetURLOrDefault is like GetURL, but returns the option's default value if
+// parsing the supplied value as a URL fails. Panics if the option does not
+// exist.
+func (cfg *Config) GetURLOrDefault(name string) *url.URL {
+	return getOrDefault(cfg, name, "URL", cfg.GetURL, parseURL)
+}
+
+// parseURL implements the parsing logic of GetURL.
+func parseURL(value string) (*url.URL, error) {
+	if value == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(value)
+	if err == nil && !u.IsAbs() {
+		err = errors.New("missing scheme")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// GetTime returns an option's value as a time.Time, parsed using the supplied
+// layout, as per time.Parse. For example, a layout of time.DateOnly accepts
+// values such as "2024-06-30". If the option value isn't set (empty string),
+// returns a zero time.Time and no error. If the option value is set but cannot
+// be parsed using layout, returns a zero time.Time and an OptionValueError.
+// Panics if the named option does not exist.
+func (cfg *Config) GetTime(name, layout string) (time.Time, error) {
+	value := cfg.Get(name)
+	t, err := parseTime(value, layout)
+	if err != nil {
+		return time.Time{}, cfg.valueError(name, value, "time in format "+layout, err)
+	}
+	return t, nil
+}
+
+// GetTimeOrDefault is like GetTime, but returns the option's default value if
+// parsing the supplied value using layout fails. Panics if the option does not
+// exist.
+func (cfg *Config) GetTimeOrDefault(name, layout string) time.Time {
+	getter := func(name string) (time.Time, error) {
+		return cfg.GetTime(name, layout)
+	}
+	parse := func(value string) (time.Time, error) {
+		return parseTime(value, layout)
+	}
+	return getOrDefault(cfg, name, "time", getter, parse)
+}
+
+// parseTime implements the parsing logic of GetTime.
+func parseTime(value, layout string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(layout, value)
+}
+
 // GetAbsPath returns an option's value as an absolute path to a file. If the
 // option value is already set to an absolute path, it is returned as-is. If
 // the option value is set to a relative path, the result depends on where the
@@ -595,6 +1194,18 @@ func (cfg *Config) GetAbsPath(name string) (string, error) {
 	return filepath.Join(workingDir, value), nil
 }
 
+// valueError returns an OptionValueError for an invalid value of the named
+// option, using the option's current source.
+func (cfg *Config) valueError(name, value, expected string, err error) error {
+	return OptionValueError{
+		Name:     name,
+		Value:    value,
+		Source:   cfg.Source(name),
+		Expected: expected,
+		Err:      err,
+	}
+}
+
 // unquote takes a string, trims whitespace on both ends, and then examines
 // whether the entire string is wrapped in quotes. If it isn't, the string
 // is returned as-is after the whitespace is trimmed. Otherwise, the string
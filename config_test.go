@@ -1,13 +1,20 @@
 package mybase
 
 import (
+	"errors"
 	"fmt"
+	"maps"
+	"net"
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp/syntax"
 	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 )
 
 func TestOptionStatus(t *testing.T) {
@@ -185,11 +192,28 @@ func TestRuntimeOverride(t *testing.T) {
 	assertOptionValue(cfg, "optional2", "overridden2")
 	assertOnCLI(cfg, "hasshort", false)
 	assertOnCLI(cfg, "optional2", false)
+	if source, ok := cfg.Source("hasshort").(StringMapValues); !ok || source["hasshort"] != "overridden1" {
+		t.Errorf("Unexpected Source for overridden option: %v", cfg.Source("hasshort"))
+	}
+
+	// Overrides should not require copying the cached values of all options
+	before := cfg.current()
+	cfg.SetRuntimeOverride("optional1", "overridden3")
+	after := cfg.current()
+	if reflect.ValueOf(before.unifiedValues).UnsafePointer() != reflect.ValueOf(after.unifiedValues).UnsafePointer() {
+		t.Error("Expected SetRuntimeOverride to reuse the cached unified values")
+	}
+	assertOptionValue(cfg, "optional1", "overridden3")
+	if changed := before.changedOptions(after); !slices.Equal(changed, []string{"optional1"}) {
+		t.Errorf("Unexpected changedOptions after SetRuntimeOverride: %v", changed)
+	}
+	cfg.MarkDirty()
+	assertOptionValue(cfg, "optional1", "overridden3")
 
 	// Confirm behaviors of clone, including use of a deep copy of the overrides
 	// map, rather than a shared reference
 	clone := cfg.Clone()
-	assertOptionValue(clone, "optional1", "")
+	assertOptionValue(clone, "optional1", "overridden3")
 	assertOptionValue(clone, "hasshort", "overridden1")
 	assertOptionValue(clone, "optional2", "overridden2")
 	assertOnCLI(clone, "hasshort", false)
@@ -209,6 +233,171 @@ func TestRuntimeOverride(t *testing.T) {
 	assertOptionValue(clone, "hasshort", "alsonew")
 }
 
+// TestConfigConcurrency is primarily useful when run with -race
+func TestConfigConcurrency(t *testing.T) {
+	cmd := simpleCommand()
+	cfg := ParseFakeCLI(t, cmd, "mycommand --hasshort=cli arg1")
+	var wg sync.WaitGroup
+	for n := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 200 {
+				switch (n + i) % 8 {
+				case 0:
+					cfg.SetRuntimeOverride("visible", strconv.Itoa(i))
+				case 1:
+					cfg.AddSource(SimpleSource(map[string]string{"bool1": "1"}))
+				case 2:
+					cfg.MarkDirty()
+				case 3:
+					cfg.Clone().Get("hasshort")
+				default:
+					cfg.Get("visible")
+					cfg.GetBool("bool1")
+					cfg.Source("hasshort")
+					cfg.Supplied("bool2")
+					cfg.Changed("truthybool")
+					cfg.OnCLI("hasshort")
+					cfg.Sources()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if !cfg.GetBool("bool1") || cfg.Get("hasshort") != "cli" || !cfg.Supplied("visible") {
+		t.Errorf("Unexpected values after concurrent access: bool1=%t, hasshort=%q, visible=%q", cfg.GetBool("bool1"), cfg.Get("hasshort"), cfg.Get("visible"))
+	}
+}
+
+func BenchmarkConfigGet(b *testing.B) {
+	cmd := simpleCommand()
+	cli, err := ParseCLI(cmd, []string{"mycommand", "--hasshort=cli", "arg1"})
+	if err != nil {
+		b.Fatalf("Unexpected error from ParseCLI: %v", err)
+	}
+	cfg := NewConfig(cli.CLI, SimpleSource(map[string]string{"visible": "file"}))
+	b.ResetTimer()
+	for range b.N {
+		cfg.Get("visible")
+		cfg.Get("hasshort")
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	dir := t.TempDir()
+	modTime := time.Now().Add(-time.Hour)
+	writeFile := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+			t.Fatalf("Unable to write %s: %v", path, err)
+		}
+		// Ensure each write results in a different mtime, regardless of the
+		// filesystem's timestamp granularity
+		modTime = modTime.Add(time.Minute)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Unable to set mtime of %s: %v", path, err)
+		}
+	}
+	assertReload := func(cfg *Config, expectChanged int, expectError bool) []*File {
+		t.Helper()
+		changed, err := cfg.Reload()
+		if expectError && err == nil {
+			t.Error("Expected error from Reload, but err was nil")
+		} else if !expectError && err != nil {
+			t.Errorf("Unexpected error from Reload: %v", err)
+		}
+		if len(changed) != expectChanged {
+			t.Errorf("Expected Reload to return %d changed files, instead found %d", expectChanged, len(changed))
+		}
+		return changed
+	}
+
+	writeFile("one.cnf", "hasshort=one\n[prod]\nvisible=one-prod\n")
+	writeFile("two.cnf", "bool1\n!include extra.cnf\n")
+	writeFile("extra.cnf", "hidden=extra\n")
+	cmd := simpleCommand()
+	cfg := ParseFakeCLI(t, cmd, "mycommand arg1")
+	one, two := NewFile(dir, "one.cnf"), NewFile(dir, "two.cnf")
+	for _, f := range []*File{one, two} {
+		if err := f.Parse(cfg); err != nil {
+			t.Fatalf("Unexpected error from Parse: %v", err)
+		}
+		cfg.AddSource(f)
+	}
+	one.UseSection("prod")
+	if cfg.Get("visible") != "one-prod" || cfg.Get("hidden") != "extra" {
+		t.Fatalf("Unexpected values prior to Reload: visible=%q, hidden=%q", cfg.Get("visible"), cfg.Get("hidden"))
+	}
+	assertReload(cfg, 0, false)
+
+	// Changes to a file should be picked up, retaining its selected sections
+	writeFile("one.cnf", "hasshort=new\n[prod]\nvisible=new-prod\n")
+	if changed := assertReload(cfg, 1, false); len(changed) == 1 && changed[0].Path() != one.Path() {
+		t.Errorf("Unexpected file returned by Reload: %s", changed[0].Path())
+	}
+	if cfg.Get("hasshort") != "new" || cfg.Get("visible") != "new-prod" || cfg.Source("visible") == one {
+		t.Errorf("Unexpected values after Reload: hasshort=%q, visible=%q", cfg.Get("hasshort"), cfg.Get("visible"))
+	}
+	if one.SectionValues("")["hasshort"] != "one" {
+		t.Error("Expected original File to be unmodified by Reload")
+	}
+
+	// Section names should be selected again after reloading, so that changes to
+	// section parents or pattern sections take effect
+	writeFile("one.cnf", "hasshort=new\n[base]\nvisible=base\n[prod : base]\n")
+	assertReload(cfg, 1, false)
+	if cfg.Get("visible") != "base" {
+		t.Errorf("Unexpected value after Reload with new parent section: visible=%q", cfg.Get("visible"))
+	}
+	writeFile("one.cnf", "hasshort=new\n[pr*]\nvisible=pattern\n")
+	assertReload(cfg, 1, false)
+	if cfg.Get("visible") != "pattern" {
+		t.Errorf("Unexpected value after Reload with new pattern section: visible=%q", cfg.Get("visible"))
+	}
+
+	// An edit which changes neither size nor modification time should still be
+	// detected, by comparing contents
+	onePath := filepath.Join(dir, "one.cnf")
+	info, err := os.Stat(onePath)
+	if err != nil {
+		t.Fatalf("Unable to stat %s: %v", onePath, err)
+	}
+	if err := os.WriteFile(onePath, []byte("hasshort=new\n[pr*]\nvisible=Pattern\n"), 0666); err != nil {
+		t.Fatalf("Unable to write %s: %v", onePath, err)
+	}
+	if err := os.Chtimes(onePath, info.ModTime(), info.ModTime()); err != nil {
+		t.Fatalf("Unable to set mtime of %s: %v", onePath, err)
+	}
+	assertReload(cfg, 1, false)
+	if cfg.Get("visible") != "Pattern" {
+		t.Errorf("Unexpected value after Reload with same-size edit: visible=%q", cfg.Get("visible"))
+	}
+	assertReload(cfg, 0, false)
+
+	// Changes to an included file should cause the including file to be reloaded
+	writeFile("extra.cnf", "hidden=changed\n")
+	assertReload(cfg, 1, false)
+	if cfg.Get("hidden") != "changed" || !cfg.GetBool("bool1") {
+		t.Errorf("Unexpected values after Reload: hidden=%q, bool1=%t", cfg.Get("hidden"), cfg.GetBool("bool1"))
+	}
+	assertReload(cfg, 0, false)
+
+	// If any changed file fails to parse, the previous values should remain
+	writeFile("one.cnf", "hasshort=newer\n")
+	writeFile("two.cnf", "doesnt-exist=1\n")
+	assertReload(cfg, 0, true)
+	if cfg.Get("hasshort") != "new" || cfg.Get("hidden") != "changed" {
+		t.Errorf("Unexpected values after failed Reload: hasshort=%q, hidden=%q", cfg.Get("hasshort"), cfg.Get("hidden"))
+	}
+	writeFile("two.cnf", "bool2\n")
+	assertReload(cfg, 2, false)
+	if cfg.Get("hasshort") != "newer" || cfg.Get("hidden") != "somedefault" || !cfg.GetBool("bool2") {
+		t.Errorf("Unexpected values after Reload: hasshort=%q, hidden=%q, bool2=%t", cfg.Get("hasshort"), cfg.Get("hidden"), cfg.GetBool("bool2"))
+	}
+}
+
 func TestGetRaw(t *testing.T) {
 	optionValues := map[string]string{
 		"basic":     "foo",
@@ -398,8 +587,11 @@ func TestGetEnum(t *testing.T) {
 		t.Errorf("Expected BaR,nil; found %s,%s", value, err)
 	}
 	value, err = cfg.GetEnum("foo", "nope", "dope")
-	if value != "" || err == nil {
-		t.Errorf("Expected error, found %s,%s", value, err)
+	var ove OptionValueError
+	if value != "" || !errors.As(err, &ove) {
+		t.Errorf("Expected OptionValueError, found %s,%v", value, err)
+	} else if ove.Name != "foo" || ove.Value != "bar" || ove.Expected != `one of "nope", "dope", ""` {
+		t.Errorf("Unexpected fields in OptionValueError: %+v", ove)
 	}
 	value, err = cfg.GetEnum("caps", "yelling", "shouting")
 	if value != "shouting" || err != nil {
@@ -411,6 +603,399 @@ func TestGetEnum(t *testing.T) {
 	}
 }
 
+func TestTypedOptions(t *testing.T) {
+	cmd := NewCommand("mycommand", "summary", "description", nil)
+	cmd.AddOptions("typed",
+		IntOption("port", 'P', 3306, "dummy description"),
+		FloatOption("ratio", 0, 0.5, "dummy description").ValueOptional(),
+		BytesOption("max-size", 0, "16M", "dummy description"),
+		EnumOption("format", 0, "auto", "dummy description", "json", "text"),
+		DurationOption("timeout", 0, 30*time.Second, "dummy description"),
+		RegexpOption("ignore-table", 0, "", "dummy description"),
+		PathOption("dir", 0, ".", "dummy description"),
+	)
+	options := cmd.Options()
+	expectedUsageNames := map[string]string{
+		"port":         "port=<int>",
+		"ratio":        "ratio[=<float>]",
+		"max-size":     "max-size=<size>",
+		"format":       "format=<value>",
+		"timeout":      "timeout=<duration>",
+		"ignore-table": "ignore-table=<regexp>",
+		"dir":          "dir=<path>",
+		"help":         "help[=value]",
+	}
+	for name, expected := range expectedUsageNames {
+		if actual := options[name].usageName(); actual != expected {
+			t.Errorf("Unexpected usageName for option %s: expected %q, found %q", name, expected, actual)
+		}
+	}
+	if usage := options["format"].Usage(20); !strings.Contains(usage, `(allowed values: "json", "text") (default "auto")`) {
+		t.Errorf("Unexpected usage for enum option: %q", usage)
+	}
+	if options["port"].Default != "3306" || options["ratio"].Default != "0.5" || options["timeout"].Default != "30s" {
+		t.Errorf("Unexpected defaults for typed options: %q, %q, %q", options["port"].Default, options["ratio"].Default, options["timeout"].Default)
+	}
+	if allowed := options["format"].AllowedValues(); !slices.Equal(allowed, []string{"json", "text"}) || options["port"].AllowedValues() != nil {
+		t.Errorf("Unexpected return from AllowedValues: %v", allowed)
+	}
+
+	cfg := ParseFakeCLI(t, cmd, "mycommand --format=JSON")
+	if value, err := cfg.GetEnum("format"); value != "json" || err != nil {
+		t.Errorf("Expected GetEnum to use option's allowed values; instead found %q, %v", value, err)
+	}
+	if value, err := cfg.GetEnum("format", "xml"); value != "" || err == nil {
+		t.Errorf("Expected GetEnum to use supplied allowed values; instead found %q, %v", value, err)
+	}
+	if actual := options["format"].AllowedValues(); !slices.Equal(actual, []string{"json", "text"}) {
+		t.Errorf("AllowedValues unexpectedly modified by GetEnum: %v", actual)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected EnumOption without allowed values to panic, but it did not")
+		}
+	}()
+	EnumOption("format", 0, "auto", "dummy description")
+}
+
+func TestValidate(t *testing.T) {
+	cmd := NewCommand("mycommand", "summary", "description", nil)
+	cmd.AddOption(IntOption("port", 'P', 3306, "dummy description"))
+	cmd.AddOption(FloatOption("ratio", 0, 0.5, "dummy description"))
+	cmd.AddOption(BytesOption("max-size", 0, "16M", "dummy description").ValueOptional())
+	cmd.AddOption(EnumOption("format", 0, "auto", "dummy description", "json", "text"))
+	cmd.AddOption(DurationOption("timeout", 0, 0, "dummy description"))
+	cmd.AddOption(RegexpOption("ignore-table", 0, "", "dummy description"))
+	cmd.AddOption(StringOption("untyped", 0, "", "dummy description"))
+	cmd.AddOption(IntOption("bad-default", 0, 0, "dummy description"))
+	cmd.AddOption(IntOption("workers", 0, 4, "dummy description").ValueOptional())
+	cmd.Options()["bad-default"].Default = "not an int"
+
+	cfg := ParseFakeCLI(t, cmd, "mycommand --port=3307 --ratio 1e3 --max-size= --format=Text --timeout=1m30s --ignore-table='^_' --untyped=whatever --workers")
+	if errs := cfg.Validate(); len(errs) > 0 {
+		t.Errorf("Expected no validation errors, instead found %v", errs)
+	}
+
+	cfg = ParseFakeCLI(t, cmd, "mycommand --port=abc --ratio=1.5 --format=xml")
+	f, err := getParsedFile(cfg, false, "max-size=12Q\n\n[production]\ntimeout=30x\nignore-table=[\n")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing file: %v", err)
+	}
+	f.UseSection("production")
+	cfg.AddSource(f)
+	errs := cfg.Validate()
+	expected := []string{
+		`Option format in command line: expected one of "json", "text", "auto", got "xml"`,
+		`Option ignore-table in /tmp/fake.cnf line 5: expected regular expression, got "["`,
+		`Option max-size in /tmp/fake.cnf line 1: expected byte size such as 64K, 128M, or 2G, got "12Q"`,
+		`Option port in command line: expected integer, got "abc"`,
+		`Option timeout in /tmp/fake.cnf line 4: expected duration such as 30s or 1h15m, or a number of seconds, got "30x"`,
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %d validation errors, instead found %d: %v", len(expected), len(errs), errs)
+	}
+	for n, err := range errs {
+		if err.Error() != expected[n] {
+			t.Errorf("Unexpected error[%d]: expected %q, found %q", n, expected[n], err.Error())
+		}
+		var ove OptionValueError
+		if !errors.As(err, &ove) || ove.Source == nil || ove.Err == nil || errors.Unwrap(err) != ove.Err {
+			t.Errorf("Unexpected error type or contents: %#v", err)
+		}
+	}
+}
+
+func TestValidateCallbacks(t *testing.T) {
+	var handlerCalls int
+	handler := func(cfg *Config) error {
+		handlerCalls++
+		return nil
+	}
+	notRoot := func(value string) error {
+		if value == "root" {
+			return errors.New("root user not permitted")
+		}
+		return nil
+	}
+	cmd := NewCommand("mycommand", "summary", "description", handler)
+	cmd.AddOption(StringOption("user", 'u', "root", "dummy description").Validate(notRoot))
+	cmd.AddOption(IntOption("port", 'P', 3306, "dummy description").Validate(func(value string) error {
+		if port, _ := strconv.Atoi(value); port > 65535 {
+			return errors.New("port out of range")
+		}
+		return nil
+	}))
+
+	// Default values aren't validated, but supplied values are
+	cfg := ParseFakeCLI(t, cmd, "mycommand --port=3307")
+	if err := cfg.HandleCommand(); err != nil || handlerCalls != 1 {
+		t.Errorf("Unexpected return from HandleCommand: %v (handler calls=%d)", err, handlerCalls)
+	}
+	cfg = ParseFakeCLI(t, cmd, "mycommand --port=99999 -u root")
+	f, err := getParsedFile(cfg, false, "port=abc\n")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing file: %v", err)
+	}
+	cfg.AddSource(f)
+	expected := []string{
+		`Option port in command line: invalid value "99999": port out of range`,
+		`Option user in command line: invalid value "root": root user not permitted`,
+	}
+	if errs := cfg.Validate(); len(errs) != 2 || errs[0].Error() != expected[0] || errs[1].Error() != expected[1] {
+		t.Errorf("Unexpected return from Validate: %v", errs)
+	}
+	err = cfg.HandleCommand()
+	if err == nil || handlerCalls != 1 {
+		t.Errorf("Expected HandleCommand to return error without calling handler; instead found %v (handler calls=%d)", err, handlerCalls)
+	} else if err.Error() != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected error message from HandleCommand: %q", err)
+	}
+
+	// Type validation takes precedence over callbacks
+	cfg = ParseFakeCLI(t, cmd, "mycommand", f)
+	if errs := cfg.Validate(); len(errs) != 1 || errs[0].Error() != `Option port in /tmp/fake.cnf line 1: expected integer, got "abc"` {
+		t.Errorf("Unexpected return from Validate: %v", errs)
+	}
+}
+
+func TestIngestionCallbacks(t *testing.T) {
+	noSpaces := func(value string) error {
+		if strings.Contains(value, " ") {
+			return errors.New("spaces not permitted")
+		}
+		return nil
+	}
+	cmd := NewCommand("mycommand", "summary", "description", nil)
+	cmd.AddOption(EnumOption("format", 'f', "auto", "dummy description", "json", "text").WithNormalizer(strings.ToLower))
+	cmd.AddOption(PathOption("dir", 'd', "", "dummy description").WithNormalizer(func(value string) string {
+		if len(value) > 1 {
+			return strings.TrimRight(value, "/")
+		}
+		return value
+	}))
+	cmd.AddOption(StringOption("schema", 's', "", "dummy description").WithValidator(noSpaces).ValueOptional())
+
+	cfg := ParseFakeCLI(t, cmd, "mycommand --format=JSON -d \"/tmp/it's here//\" --schema=")
+	if actual := cfg.GetRaw("format"); actual != "json" {
+		t.Errorf("Expected normalized value, instead found %q", actual)
+	}
+	if actual := cfg.GetRaw("dir"); actual != "/tmp/it's here" {
+		t.Errorf("Expected normalized value, instead found %q", actual)
+	}
+	if actual := cfg.GetRaw("schema"); actual != "''" {
+		t.Errorf("Expected empty value to remain quote-wrapped, instead found %q", actual)
+	}
+
+	f, err := getParsedFile(cfg, false, "format=Text\ndir='/var/\\'lib/'\nschema=\"with\\\" quote\"\n")
+	if err == nil {
+		t.Fatal("Expected error parsing file with invalid value, but err was nil")
+	} else if expected := `Option schema in /tmp/fake.cnf line 3: invalid value "with" quote": spaces not permitted`; err.Error() != expected {
+		t.Errorf("Unexpected error message: expected %q, found %q", expected, err.Error())
+	}
+	f, err = getParsedFile(cfg, false, "format=Text\ndir='/var/\\'lib/'\n")
+	if err != nil {
+		t.Fatalf("Unexpected error parsing file: %v", err)
+	}
+	if f.sectionIndex[""].Values["format"] != "text" || f.sectionIndex[""].Values["dir"] != `'/var/\'lib'` {
+		t.Errorf("Unexpected values from normalization in file: %v", f.sectionIndex[""].Values)
+	}
+
+	for _, commandLine := range []string{"mycommand --schema='a b'", "mycommand -s'a b'"} {
+		_, err := ParseCLI(cmd, tokenizeCommandLine(t, commandLine))
+		var ove OptionValueError
+		if !errors.As(err, &ove) || ove.Source == nil || ove.Name != "schema" || ove.Value != "a b" {
+			t.Errorf("Unexpected error from ParseCLI(%q): %#v", commandLine, err)
+		} else if expected := `Option schema in command line: invalid value "a b": spaces not permitted`; err.Error() != expected {
+			t.Errorf("Unexpected error message: expected %q, found %q", expected, err.Error())
+		}
+	}
+
+	// Validate also runs parse-time validators, for values from other sources
+	cfg = ParseFakeCLI(t, cmd, "mycommand", StringMapValues{"schema": "a b"})
+	if errs := cfg.Validate(); len(errs) != 1 {
+		t.Errorf("Expected 1 validation error, instead found %v", errs)
+	}
+}
+
+func TestGetMap(t *testing.T) {
+	optionValues := map[string]string{
+		"simple":   "a=1,b=2",
+		"spaces":   " a = 1 , b=2=3 ,, ",
+		"quoted":   `a="x,y",'b=c'=z,d=\,e`,
+		"wrapped":  `"a=1,b='2'"`,
+		"semi":     "a:1;b:2",
+		"dupes":    "a=1,a=2",
+		"blank":    "",
+		"nokv":     "a=1,b",
+		"emptykey": "=1",
+	}
+	cfg := simpleConfig(optionValues)
+	cases := []struct {
+		name      string
+		pairDelim rune
+		kvDelim   rune
+		expected  map[string]string
+	}{
+		{"simple", ',', '=', map[string]string{"a": "1", "b": "2"}},
+		{"spaces", ',', '=', map[string]string{"a": "1", "b": "2=3"}},
+		{"quoted", ',', '=', map[string]string{"a": "x,y", "b=c": "z", "d": `\,e`}},
+		{"wrapped", ',', '=', map[string]string{"a": "1", "b": "2"}},
+		{"semi", ';', ':', map[string]string{"a": "1", "b": "2"}},
+		{"dupes", ',', '=', map[string]string{"a": "2"}},
+		{"blank", ',', '=', map[string]string{}},
+	}
+	for _, c := range cases {
+		actual, err := cfg.GetMap(c.name, c.pairDelim, c.kvDelim)
+		if err != nil || !maps.Equal(actual, c.expected) || actual == nil {
+			t.Errorf("Unexpected return from GetMap(%q): expected %v, found %v, %v", c.name, c.expected, actual, err)
+		}
+	}
+	for _, name := range []string{"nokv", "emptykey"} {
+		var ove OptionValueError
+		if actual, err := cfg.GetMap(name, ',', '='); actual != nil || !errors.As(err, &ove) || ove.Expected != `key=value pairs separated by ","` {
+			t.Errorf("Unexpected return from GetMap(%q): %v, %v", name, actual, err)
+		}
+	}
+
+	cmd := simpleCommand()
+	cmd.AddOption(MapOption("labels", 0, "", "dummy description"))
+	cfg = ParseFakeCLI(t, cmd, "mycommand --labels=a=1,b arg1")
+	if errs := cfg.Validate(); len(errs) != 1 {
+		t.Errorf("Expected 1 validation error, instead found %v", errs)
+	}
+	if usage := cmd.Options()["labels"].Usage(40); !strings.Contains(usage, "--labels=<key=value,...>") {
+		t.Errorf("Unexpected usage for map option: %q", usage)
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	cmd := simpleCommand()
+	cmd.AddOption(StringOption("ignore-table", 'i', "", "dummy description").Repeatable())
+	cmd.AddOption(StringOption("schema", 0, "default", "dummy description").Repeatable())
+	assertAll := func(cfg *Config, name string, expected ...string) {
+		t.Helper()
+		if actual := cfg.GetAll(name); !slices.Equal(actual, expected) || actual == nil {
+			t.Errorf("Unexpected return from GetAll(%q): expected %q, found %q", name, expected, actual)
+		}
+	}
+
+	cfg := ParseFakeCLI(t, cmd, "mycommand arg1")
+	assertAll(cfg, "ignore-table")
+	assertAll(cfg, "schema", "default")
+	assertAll(cfg, "hasshort")
+
+	// Repeated occurrences on the command-line accumulate, while non-repeatable
+	// options remain last-wins
+	cfg = ParseFakeCLI(t, cmd, "mycommand --ignore-table=a -ib -s x --hasshort=y -i 'c d' arg1")
+	assertAll(cfg, "ignore-table", "a", "b", "c d")
+	assertAll(cfg, "hasshort", "y")
+	if value := cfg.Get("ignore-table"); value != "c d" {
+		t.Errorf("Unexpected value from Get: %q", value)
+	}
+	cfg = ParseFakeCLI(t, cmd, "mycommand -ia --skip-ignore-table -ib --ignore-table=c arg1")
+	assertAll(cfg, "ignore-table", "b", "c")
+
+	// Duplicates within a section of a file accumulate, and values from multiple
+	// selected sections and multiple sources are combined in priority order
+	f, err := getParsedFile(cfg, false, "ignore-table=f1\nignore-table=f2\nschema=s1\n[prod]\nignore-table=p1\nhasshort=p\n[staging]\nschema=\nschema=s2\n")
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+	if values, ok := f.AllOptionValues("ignore-table"); !ok || !slices.Equal(values, []string{"f1", "f2"}) {
+		t.Errorf("Unexpected return from File.AllOptionValues: %q, %t", values, ok)
+	}
+	f.UseSection("prod")
+	cfg = ParseFakeCLI(t, cmd, "mycommand --ignore-table=c1 arg1", f)
+	assertAll(cfg, "ignore-table", "f1", "f2", "p1", "c1")
+	assertAll(cfg, "schema", "s1")
+	assertAll(cfg, "hasshort", "p")
+
+	// An empty value in a higher-priority section or source discards earlier values
+	f.UseSection("staging")
+	cfg = ParseFakeCLI(t, cmd, "mycommand arg1", f)
+	assertAll(cfg, "schema", "s2")
+	cfg = ParseFakeCLI(t, cmd, "mycommand --skip-ignore-table --schema= arg1", f)
+	assertAll(cfg, "ignore-table")
+	assertAll(cfg, "schema")
+
+	// Modifying a file's value discards its repeated values
+	f.SetOptionValue("", "ignore-table", "f3")
+	cfg = ParseFakeCLI(t, cmd, "mycommand -i c1 arg1", f, StringMapValues{"ignore-table": "m1"})
+	assertAll(cfg, "ignore-table", "f3", "m1", "c1")
+
+	// Writing the modified file should remove all earlier lines for the option,
+	// so that reparsing it does not resurrect the discarded values
+	f.Dir = t.TempDir()
+	if err := f.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	f2 := NewFile(f.Dir, f.Name)
+	if err := f2.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse of rewritten file: %v", err)
+	}
+	cfg = ParseFakeCLI(t, cmd, "mycommand arg1", f2)
+	assertAll(cfg, "ignore-table", "f3")
+	if strings.Count(f2.contents, "ignore-table") != 2 {
+		t.Errorf("Unexpected contents after Write:\n%s", f2.contents)
+	}
+
+	if usage := cmd.Options()["ignore-table"].Usage(40); !strings.Contains(usage, "(may be repeated)") {
+		t.Errorf("Expected usage of repeatable option to mention repetition, but it did not: %q", usage)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Repeatable to panic on boolean option, but it did not")
+		}
+	}()
+	BoolOption("foo", 0, false, "dummy description").Repeatable()
+}
+
+func TestGetArgs(t *testing.T) {
+	cmd := NewCommand("mycommand", "summary", "description", nil)
+	cmd.AddOption(BoolOption("force", 'f', false, "dummy description"))
+	cmd.AddArg("environment", "", true)
+	cmd.AddVariadicArg("paths", 2)
+
+	cfg := ParseFakeCLI(t, cmd, "mycommand production a -f b 'c d'")
+	if actual := cfg.GetArgs("paths"); !slices.Equal(actual, []string{"a", "b", "c d"}) {
+		t.Errorf("Unexpected return from GetArgs: %#v", actual)
+	}
+	if cfg.Get("environment") != "production" || !cfg.GetBool("force") || !cfg.OnCLI("paths") {
+		t.Errorf("Unexpected config state: environment=%q, force=%t, paths on CLI=%t", cfg.Get("environment"), cfg.GetBool("force"), cfg.OnCLI("paths"))
+	}
+
+	// Args following the option terminator should be treated as positional,
+	// even if they begin with a dash
+	cfg = ParseFakeCLI(t, cmd, "mycommand production a -- -f --b")
+	if actual := cfg.GetArgs("paths"); !slices.Equal(actual, []string{"a", "-f", "--b"}) || cfg.GetBool("force") {
+		t.Errorf("Unexpected return from GetArgs: %#v", actual)
+	}
+
+	// Too few args should be an error
+	for _, commandLine := range []string{"mycommand production a", "mycommand production -- -f"} {
+		if _, err := ParseCLI(cmd, tokenizeCommandLine(t, commandLine)); err == nil {
+			t.Errorf("Expected error from ParseCLI(%q), but err was nil", commandLine)
+		}
+	}
+
+	// Optional variadic arg
+	cmd = NewCommand("mycommand", "summary", "description", nil)
+	cmd.AddVariadicArg("paths", 0)
+	cfg = ParseFakeCLI(t, cmd, "mycommand")
+	if actual := cfg.GetArgs("paths"); actual == nil || len(actual) != 0 || cfg.Supplied("paths") {
+		t.Errorf("Unexpected return from GetArgs: %#v", actual)
+	}
+
+	// GetArgs should panic on non-variadic names
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected GetArgs to panic on nonexistent arg, but it did not")
+		}
+	}()
+	cfg.GetArgs("doesnt-exist")
+}
+
 func TestGetBytes(t *testing.T) {
 	optionValues := map[string]string{
 		"simple-ok":     "1234",
@@ -482,6 +1067,260 @@ func TestGetRegexp(t *testing.T) {
 	}
 }
 
+func TestGetterErrors(t *testing.T) {
+	cmd := simpleCommand()
+	cmd.AddOption(StringOption("count", 0, "0", "dummy description"))
+	cmd.AddOption(StringOption("size", 0, "", "dummy description"))
+	cmd.AddOption(StringOption("format", 0, "json", "dummy description"))
+	cmd.AddOption(StringOption("pattern", 0, "", "dummy description"))
+	cfg := ParseFakeCLI(t, cmd, "mycommand --size=12x --format=xml arg1")
+	f, err := getParsedFile(cfg, false, "count=abc\npattern='[a-z'\n")
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+	cfg.AddSource(f)
+
+	assertValueError := func(err error, name, value, expectedMessage string) {
+		t.Helper()
+		var ove OptionValueError
+		if !errors.As(err, &ove) {
+			t.Errorf("Expected error to be OptionValueError, instead found %T %v", err, err)
+		} else if ove.Name != name || ove.Value != value || ove.Source != cfg.Source(name) || ove.Expected == "" {
+			t.Errorf("Unexpected fields in OptionValueError: %+v", ove)
+		} else if err.Error() != expectedMessage {
+			t.Errorf("Unexpected error message: expected %q, found %q", expectedMessage, err.Error())
+		}
+	}
+	_, err = cfg.GetInt("count")
+	assertValueError(err, "count", "abc", `Option count in /tmp/fake.cnf line 1: expected integer, got "abc"`)
+	if !errors.Is(err, strconv.ErrSyntax) {
+		t.Errorf("Expected GetInt error to wrap strconv.ErrSyntax, but it did not: %v", err)
+	}
+	_, err = cfg.GetBytes("size")
+	assertValueError(err, "size", "12x", `Option size in command line: expected byte size such as 64K, 128M, or 2G, got "12x"`)
+	_, err = cfg.GetEnum("format", "json", "yaml")
+	assertValueError(err, "format", "xml", `Option format in command line: expected one of "json", "yaml", got "xml"`)
+	_, err = cfg.GetRegexp("pattern")
+	assertValueError(err, "pattern", "[a-z", `Option pattern in /tmp/fake.cnf line 2: expected regular expression, got "[a-z"`)
+	var syntaxErr *syntax.Error
+	if !errors.As(err, &syntaxErr) {
+		t.Errorf("Expected GetRegexp error to wrap *syntax.Error, but it did not: %v", err)
+	}
+}
+
+func TestGetCount(t *testing.T) {
+	cmd := simpleCommand()
+	cmd.AddOption(CountOption("verbose", 'v', 0, "dummy description"))
+	cmd.AddOption(CountOption("quiet", 'q', 2, "dummy description"))
+	cases := map[string]int{
+		"mycommand arg1":                               0,
+		"mycommand -v arg1":                            1,
+		"mycommand -vvv arg1":                          3,
+		"mycommand -v -v arg1 -v":                      3,
+		"mycommand --verbose --verbose arg1":           2,
+		"mycommand -Bv --verbose -v arg1":              3,
+		"mycommand --verbose=5 -v arg1":                6,
+		"mycommand -vv --skip-verbose arg1":            0,
+		"mycommand -vv --skip-verbose -v arg1":         1,
+		"mycommand --verbose=true arg1":                1,
+		"mycommand --verbose=off arg1":                 0,
+		"mycommand -vvv --verbose=2 arg1":              2,
+		"mycommand --disable-verbose --verbose arg1":   1,
+		"mycommand --loose-verbose -vvvv --quiet arg1": 5,
+	}
+	for commandLine, expected := range cases {
+		cfg := ParseFakeCLI(t, cmd, commandLine)
+		if actual := cfg.GetCount("verbose"); actual != expected {
+			t.Errorf("Unexpected GetCount from %q: expected %d, found %d", commandLine, expected, actual)
+		}
+	}
+
+	// Default values, and values from option files
+	cfg := ParseFakeCLI(t, cmd, "mycommand arg1")
+	if actual := cfg.GetCount("quiet"); actual != 2 {
+		t.Errorf("Unexpected GetCount for default value: expected 2, found %d", actual)
+	}
+	if actual := cfg.GetCount("bool1"); actual != 0 {
+		t.Errorf("Unexpected GetCount for non-count bool option: expected 0, found %d", actual)
+	}
+	f, err := getParsedFile(cfg, false, "verbose=3\nquiet\nbool1\n")
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+	cfg.AddSource(f)
+	if actual := cfg.GetCount("verbose"); actual != 3 {
+		t.Errorf("Unexpected GetCount for option file value: expected 3, found %d", actual)
+	}
+	if actual := cfg.GetCount("quiet"); actual != 1 {
+		t.Errorf("Unexpected GetCount for valueless option file value: expected 1, found %d", actual)
+	}
+	if actual := cfg.GetCount("bool1"); actual != 1 {
+		t.Errorf("Unexpected GetCount for non-count bool option: expected 1, found %d", actual)
+	}
+
+	// Validation: any non-integer value is treated as a boolean, but negative
+	// counts are not permitted
+	f, err = getParsedFile(cfg, false, "verbose=yes\nquiet=off\n")
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+	cfg = ParseFakeCLI(t, cmd, "mycommand arg1", f)
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected validation error for boolean count values: %v", err)
+	} else if actual := cfg.GetCount("verbose"); actual != 1 {
+		t.Errorf("Unexpected GetCount for boolean count value: expected 1, found %d", actual)
+	}
+	cfg = ParseFakeCLI(t, cmd, "mycommand --verbose=-1 arg1")
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for negative count value, but no error returned")
+	}
+	cfg = ParseFakeCLI(t, cmd, "mycommand -vvv --quiet=0 arg1")
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Unexpected validation error: %v", err)
+	}
+
+	// Usage
+	if actual := cmd.Options()["quiet"].DefaultUsage(); actual != " (default 2)" {
+		t.Errorf("Unexpected DefaultUsage for count option: %q", actual)
+	}
+	if actual := cmd.Options()["verbose"].PrintableDefault(); actual != "0" {
+		t.Errorf("Unexpected PrintableDefault for count option: %q", actual)
+	}
+}
+
+func TestGetFloat(t *testing.T) {
+	cmd := simpleCommand()
+	cmd.AddOption(FloatOption("ratio", 0, 0.5, "dummy description"))
+	cmd.AddOption(FloatOption("other-ratio", 0, 0.25, "dummy description"))
+	cfg := ParseFakeCLI(t, cmd, "mycommand --ratio=1.5e2 --other-ratio=abc arg1")
+	if value, err := cfg.GetFloat("ratio"); value != 150 || err != nil {
+		t.Errorf("Unexpected return from GetFloat: %v, %v", value, err)
+	}
+	var ove OptionValueError
+	if value, err := cfg.GetFloat("other-ratio"); value != 0 || !errors.As(err, &ove) || ove.Expected != "number" {
+		t.Errorf("Unexpected return from GetFloat: %v, %v", value, err)
+	}
+	if value := cfg.GetFloatOrDefault("other-ratio"); value != 0.25 {
+		t.Errorf("Unexpected return from GetFloatOrDefault: %v", value)
+	}
+}
+
+func TestGetDuration(t *testing.T) {
+	values := map[string]string{
+		"go-ok":      "1h15m30s",
+		"seconds-ok": "90",
+		"decimal-ok": "1.5",
+		"blank-ok":   "",
+		"unit-fail":  "3 days",
+		"word-fail":  "forever",
+	}
+	cfg := simpleConfig(values)
+	expected := map[string]time.Duration{
+		"go-ok":      time.Hour + 15*time.Minute + 30*time.Second,
+		"seconds-ok": 90 * time.Second,
+		"decimal-ok": 1500 * time.Millisecond,
+		"blank-ok":   0,
+		"unit-fail":  0,
+		"word-fail":  0,
+	}
+	for name, expect := range expected {
+		value, err := cfg.GetDuration(name)
+		if value != expect {
+			t.Errorf("Expected GetDuration(%s) to return %v, instead found %v", name, expect, value)
+		}
+		if strings.HasSuffix(name, "-ok") && err != nil {
+			t.Errorf("Unexpected error from GetDuration(%s): %v", name, err)
+		} else if strings.HasSuffix(name, "-fail") && err == nil {
+			t.Errorf("Expected error from GetDuration(%s), but err was nil", name)
+		}
+	}
+
+	cmd := simpleCommand()
+	cmd.AddOption(DurationOption("timeout", 0, 30*time.Second, "dummy description"))
+	cfg = ParseFakeCLI(t, cmd, "mycommand --timeout=soon arg1")
+	if value := cfg.GetDurationOrDefault("timeout"); value != 30*time.Second {
+		t.Errorf("Unexpected return from GetDurationOrDefault: %v", value)
+	}
+	cfg = ParseFakeCLI(t, cmd, "mycommand --timeout=45 arg1")
+	if errs := cfg.Validate(); len(errs) > 0 {
+		t.Errorf("Expected bare number of seconds to pass validation, instead found %v", errs)
+	}
+}
+
+func TestGetNetworkValues(t *testing.T) {
+	cmd := simpleCommand()
+	cmd.AddOption(StringOption("ip", 0, "127.0.0.1", "dummy description"))
+	cmd.AddOption(StringOption("ip6", 0, "", "dummy description"))
+	cmd.AddOption(StringOption("cidr", 0, "10.0.0.0/8", "dummy description"))
+	cmd.AddOption(StringOption("url", 0, "https://example.com/", "dummy description"))
+	cfg := ParseFakeCLI(t, cmd, "mycommand --ip6=2001:db8::1 --cidr=192.0.2.7/24 --url=http://user@host:8080/path?x=1 arg1")
+	if ip, err := cfg.GetIP("ip"); err != nil || !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Unexpected return from GetIP: %v, %v", ip, err)
+	}
+	if ip, err := cfg.GetIP("ip6"); err != nil || ip.String() != "2001:db8::1" {
+		t.Errorf("Unexpected return from GetIP: %v, %v", ip, err)
+	}
+	if ipNet, err := cfg.GetIPNet("cidr"); err != nil || ipNet.String() != "192.0.2.0/24" {
+		t.Errorf("Unexpected return from GetIPNet: %v, %v", ipNet, err)
+	}
+	if u, err := cfg.GetURL("url"); err != nil || u.Hostname() != "host" || u.Port() != "8080" || u.Query().Get("x") != "1" {
+		t.Errorf("Unexpected return from GetURL: %v, %v", u, err)
+	}
+	if ip, err := cfg.GetIP("visible"); ip != nil || err != nil {
+		t.Errorf("Expected GetIP on blank value to return nil, nil; instead found %v, %v", ip, err)
+	}
+
+	cfg = ParseFakeCLI(t, cmd, "mycommand --ip=300.1.1.1 --cidr=10.0.0.0 --url=/relative/path arg1")
+	var ove OptionValueError
+	if ip, err := cfg.GetIP("ip"); ip != nil || !errors.As(err, &ove) || ove.Value != "300.1.1.1" {
+		t.Errorf("Unexpected return from GetIP: %v, %v", ip, err)
+	}
+	if ipNet, err := cfg.GetIPNet("cidr"); ipNet != nil || !errors.As(err, &ove) {
+		t.Errorf("Unexpected return from GetIPNet: %v, %v", ipNet, err)
+	}
+	if u, err := cfg.GetURL("url"); u != nil || !errors.As(err, &ove) {
+		t.Errorf("Unexpected return from GetURL: %v, %v", u, err)
+	}
+	if ip := cfg.GetIPOrDefault("ip"); !ip.Equal(net.IPv4(127, 0, 0, 1)) {
+		t.Errorf("Unexpected return from GetIPOrDefault: %v", ip)
+	}
+	if ipNet := cfg.GetIPNetOrDefault("cidr"); ipNet.String() != "10.0.0.0/8" {
+		t.Errorf("Unexpected return from GetIPNetOrDefault: %v", ipNet)
+	}
+	if u := cfg.GetURLOrDefault("url"); u.String() != "https://example.com/" {
+		t.Errorf("Unexpected return from GetURLOrDefault: %v", u)
+	}
+}
+
+func TestGetTime(t *testing.T) {
+	cmd := simpleCommand()
+	cmd.AddOption(StringOption("since", 0, "2024-01-01", "dummy description"))
+	cfg := ParseFakeCLI(t, cmd, "mycommand --since=2024-06-30 arg1")
+	expected := time.Date(2024, 6, 30, 0, 0, 0, 0, time.UTC)
+	if value, err := cfg.GetTime("since", time.DateOnly); err != nil || !value.Equal(expected) {
+		t.Errorf("Unexpected return from GetTime: %v, %v", value, err)
+	}
+	var ove OptionValueError
+	if value, err := cfg.GetTime("since", time.Kitchen); !value.IsZero() || !errors.As(err, &ove) || ove.Expected != "time in format "+time.Kitchen {
+		t.Errorf("Unexpected return from GetTime: %v, %v", value, err)
+	}
+	if value, err := cfg.GetTime("visible", time.DateOnly); !value.IsZero() || err != nil {
+		t.Errorf("Expected GetTime on blank value to return zero time and nil error; instead found %v, %v", value, err)
+	}
+
+	cfg = ParseFakeCLI(t, cmd, "mycommand --since=yesterday arg1")
+	expected = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if value := cfg.GetTimeOrDefault("since", time.DateOnly); !value.Equal(expected) {
+		t.Errorf("Unexpected return from GetTimeOrDefault: %v", value)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected GetTimeOrDefault to panic when default fails parsing, but it did not")
+		}
+	}()
+	cfg.GetTimeOrDefault("since", time.Kitchen)
+}
+
 func TestGetAbsPath(t *testing.T) {
 	wd, err := os.Getwd()
 	if err != nil {
@@ -0,0 +1,125 @@
+package mybase
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// EnvSource represents option values supplied via environment variables. Each
+// variable whose name begins with Prefix is mapped to an option name by
+// stripping the prefix, and then applying the same normalization rules as
+// NormalizeOptionToken: the name is lowercased and underscores are converted to
+// dashes. For example, with a prefix of "SKEEMA_", the variable SKEEMA_HOST
+// supplies a value for option host.
+// Option name modifiers work the same way as in option files: a variable such as
+// SKEEMA_SKIP_FOO disables boolean option foo, and a variable such as
+// SKEEMA_LOOSE_FOO is ignored if no option foo exists.
+type EnvSource struct {
+	Prefix               string
+	IgnoreUnknownOptions bool
+	values               map[string]string  // mapping of option name => value as string
+	varNames             map[string]string  // mapping of option name => env var name that supplied it
+	opts                 map[string]*Option // mapping of option name => option definition
+	parsed               bool
+}
+
+// NewEnvSource returns a value representing environment variables beginning
+// with the supplied prefix. If the prefix is non-empty and does not already end
+// in an underscore, one is appended automatically.
+func NewEnvSource(prefix string) *EnvSource {
+	if prefix != "" && !strings.HasSuffix(prefix, "_") {
+		prefix += "_"
+	}
+	return &EnvSource{
+		Prefix:   prefix,
+		values:   make(map[string]string),
+		varNames: make(map[string]string),
+		opts:     make(map[string]*Option),
+	}
+}
+
+func (env *EnvSource) String() string {
+	return "environment"
+}
+
+// Parse examines the current environment, storing the values of all variables
+// beginning with env.Prefix. A Config object must be supplied so that the list
+// of valid Options is known. In the unusual situation of multiple variables
+// mapping to the same option name (e.g. SKEEMA_FOO and SKEEMA_SKIP_FOO), the
+// variable name sorting last takes precedence.
+func (env *EnvSource) Parse(cfg *Config) error {
+	environ := os.Environ()
+	sort.Strings(environ)
+	for _, kv := range environ {
+		varName, value, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(varName, env.Prefix) || len(varName) == len(env.Prefix) {
+			continue
+		}
+		key, value, _, loose := NormalizeOptionToken(varName[len(env.Prefix):] + "=" + value)
+		if key == "" {
+			continue
+		}
+		opt := cfg.FindOption(key)
+		if opt == nil {
+			if loose || env.IgnoreUnknownOptions {
+				continue
+			}
+			return OptionNotDefinedError{key, "environment variable " + varName}
+		}
+		if value == "" && opt.Type == OptionTypeString {
+			// Store empty strings as quote-wrapped empty strings, for consistency with
+			// how CommandLine and File handle explicitly-empty values
+			value = "''"
+		}
+		value, err := opt.ingestValue(value, env, "environment variable "+varName)
+		if err != nil {
+			return err
+		}
+		env.values[opt.Name] = value
+		env.varNames[opt.Name] = varName
+		env.opts[opt.Name] = opt
+	}
+	env.parsed = true
+	return nil
+}
+
+// OptionValue returns the value for the requested option, if it was supplied
+// by an environment variable at the time Parse was called. Panics if Parse has
+// not been called yet, as this would indicate a bug. This satisfies the
+// OptionValuer interface, allowing an EnvSource to be used as an option source
+// in Config.
+func (env *EnvSource) OptionValue(optionName string) (string, bool) {
+	if !env.parsed {
+		panic(fmt.Errorf("Call to OptionValue(\"%s\") on unparsed environment source", optionName))
+	}
+	value, ok := env.values[optionName]
+	return value, ok
+}
+
+// DescribeSource returns a description of which environment variable supplied
+// the named option. This satisfies the SourceDescriber interface.
+func (env *EnvSource) DescribeSource(optionName string) string {
+	if varName, ok := env.varNames[optionName]; ok {
+		return "environment variable " + varName
+	}
+	return env.String()
+}
+
+// DeprecationWarnings returns a slice of warning messages for usage of
+// deprecated options in environment variables. This satisfies the
+// DeprecationWarner interface.
+func (env *EnvSource) DeprecationWarnings() []string {
+	if !env.parsed {
+		panic(errors.New("Call to DeprecationWarnings() on unparsed environment source"))
+	}
+	var warnings []string
+	for name, opt := range env.opts {
+		if opt.Deprecated() {
+			warnings = append(warnings, "Environment variable "+env.varNames[name]+": Option "+name+" is deprecated. "+opt.deprecationDetails)
+		}
+	}
+	return warnings
+}
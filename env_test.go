@@ -0,0 +1,64 @@
+package mybase
+
+import (
+	"testing"
+)
+
+func TestEnvSource(t *testing.T) {
+	cmd := NewCommand("mycommand", "summary", "description", nil)
+	cmd.AddOption(StringOption("host", 'h', "localhost", "dummy description"))
+	cmd.AddOption(StringOption("schema", 0, "", "dummy description").ValueOptional())
+	cmd.AddOption(StringOption("old-thing", 0, "", "dummy description").MarkDeprecated("details"))
+	cmd.AddOption(BoolOption("foreign-key-checks", 0, true, "dummy description"))
+	cmd.AddOption(BoolOption("verbose", 0, false, "dummy description"))
+
+	t.Setenv("MYTEST_HOST", "db.example.com")
+	t.Setenv("MYTEST_SCHEMA", "")
+	t.Setenv("MYTEST_SKIP_FOREIGN_KEY_CHECKS", "1")
+	t.Setenv("MYTEST_Verbose", "on")
+	t.Setenv("MYTEST_LOOSE_DOESNT_EXIST", "whatever")
+	t.Setenv("MYTEST_OLD_THING", "foo")
+	t.Setenv("MYTESTING", "not matching prefix")
+
+	cfg := ParseFakeCLI(t, cmd, "mycommand")
+	env := NewEnvSource("MYTEST")
+	if err := env.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	cfg.AddSource(env)
+
+	if cfg.Get("host") != "db.example.com" || cfg.DescribeSource("host") != "environment variable MYTEST_HOST" {
+		t.Errorf("Unexpected value or source for host: %q from %s", cfg.Get("host"), cfg.DescribeSource("host"))
+	}
+	if !cfg.Supplied("schema") || !cfg.SuppliedWithValue("schema") || cfg.GetRaw("schema") != "''" {
+		t.Errorf("Unexpected behavior of empty-valued env var: GetRaw=%q", cfg.GetRaw("schema"))
+	}
+	if cfg.GetBool("foreign-key-checks") || !cfg.Changed("foreign-key-checks") {
+		t.Error("Expected foreign-key-checks to be disabled by environment variable, but it was not")
+	}
+	if !cfg.GetBool("verbose") || cfg.DescribeSource("verbose") != "environment variable MYTEST_Verbose" {
+		t.Errorf("Unexpected value or source for verbose: %t from %s", cfg.GetBool("verbose"), cfg.DescribeSource("verbose"))
+	}
+	if warnings := cfg.DeprecatedOptionUsage(); len(warnings) != 1 || warnings[0] != "Environment variable MYTEST_OLD_THING: Option old-thing is deprecated. details" {
+		t.Errorf("Unexpected deprecation warnings: %v", warnings)
+	}
+
+	// CLI takes precedence over env vars
+	cfg = ParseFakeCLI(t, cmd, "mycommand --host=other")
+	cfg.AddSource(env)
+	if cfg.Get("host") != "other" || !cfg.OnCLI("host") || cfg.DescribeSource("host") != "command line" {
+		t.Errorf("Unexpected value or source for host: %q from %s", cfg.Get("host"), cfg.DescribeSource("host"))
+	}
+
+	// Unknown options are an error, unless ignored
+	t.Setenv("MYTEST_DOESNT_EXIST", "whatever")
+	env = NewEnvSource("MYTEST_")
+	if err := env.Parse(cfg); err == nil {
+		t.Error("Expected error from Parse due to unknown option, but err was nil")
+	}
+	env = NewEnvSource("MYTEST_")
+	env.IgnoreUnknownOptions = true
+	if err := env.Parse(cfg); err != nil {
+		t.Errorf("Unexpected error from Parse: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package mybase
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Provenance describes one source which supplies a value for an option, as
+// returned by Config.Explain.
+type Provenance struct {
+	Source      OptionValuer // Source of the value
+	Description string       // Human-readable description of the source, including file path and line number where applicable; see Config.DescribeSource
+	Section     string       // Name of the section which supplied the value, if Source is a *File
+	Value       string       // Raw value, as-is from the source
+	Won         bool         // true if this is the value used by the Config, i.e. from the highest-priority source
+}
+
+func (p Provenance) String() string {
+	var marker, section string
+	if p.Won {
+		marker = "*"
+	}
+	if p.Section != "" {
+		section = " [" + p.Section + "]"
+	}
+	return fmt.Sprintf("%1s %s%s: %s", marker, p.Description, section, p.Value)
+}
+
+// Explain returns information on every source which supplies a value for the
+// supplied option name, in the same order as Sources: from lowest priority to
+// highest priority. The first element is typically the option's default
+// value, and the last element is the value actually used by cfg, with its Won
+// field set to true. This is useful for debugging why an option has a
+// particular value. Panics if the option does not exist, since this is
+// indicative of programmer error.
+func (cfg *Config) Explain(name string) []Provenance {
+	cache := cfg.current()
+	winner, ok := cache.source(name)
+	winningValue, _ := cache.value(name)
+	if !ok {
+		panic(fmt.Errorf("Assertion failed: called Explain on unknown option %s", name))
+	}
+	var result []Provenance
+	for _, source := range cfg.Sources() {
+		value, ok := source.OptionValue(name)
+		if !ok {
+			continue
+		}
+		p := Provenance{
+			Source:      source,
+			Description: describeSource(source, name),
+			Value:       value,
+		}
+		if f, ok := source.(*File); ok {
+			if section := f.valueSection(name); section != nil {
+				p.Section = section.Name
+			}
+		}
+		result = append(result, p)
+	}
+
+	// Mark the winning source. Usually this is the final entry, but positional
+	// args supplied on the command-line are not provided by CommandLine's
+	// OptionValue, so they must be appended.
+	if n := len(result) - 1; n >= 0 && sameSource(result[n].Source, winner) && result[n].Value == winningValue {
+		result[n].Won = true
+	} else {
+		result = append(result, Provenance{
+			Source:      winner,
+			Description: describeSource(winner, name),
+			Value:       winningValue,
+			Won:         true,
+		})
+	}
+	return result
+}
+
+// Dump writes a report to w, describing the value and all sources of every
+// option of the current command, in order by option name. Each option's
+// sources are listed as per Explain, with the winning source marked with an
+// asterisk.
+func (cfg *Config) Dump(w io.Writer) error {
+	options := cfg.CLI.Command.Options()
+	names := make([]string, 0, len(options))
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s = %s\n", name, cfg.GetRaw(name))
+		for _, p := range cfg.Explain(name) {
+			fmt.Fprintf(&b, "  %s\n", p)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// AddPrintConfigOption adds a hidden boolean option "print-config" to cmd,
+// which is inherited by any subcommands. If this option is enabled on the
+// command-line or in an option file, HandleCommand prints the report from
+// Config.Dump to STDOUT instead of running the command's handler. Typically
+// this method should be called on the top-level command.
+func (cmd *Command) AddPrintConfigOption() {
+	cmd.AddOption(BoolOption("print-config", 0, false, "Print option values and their sources instead of running the command").Hidden())
+	cmd.printConfig = true
+}
+
+// printConfigWanted returns true if cfg's command (or any of its ancestors)
+// had AddPrintConfigOption called on it, and that option is enabled.
+func (cfg *Config) printConfigWanted() bool {
+	for cmd := cfg.CLI.Command; cmd != nil; cmd = cmd.ParentCommand {
+		if cmd.printConfig {
+			return cfg.GetBool("print-config")
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package mybase
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestConfigExplain(t *testing.T) {
+	cmd := simpleCommand()
+	cfg := ParseFakeCLI(t, cmd, "mycommand --hasshort=cli arg1")
+	f, err := getParsedFile(cfg, false, "hasshort=file\nvisible=file\n[prod]\nvisible=prod\n")
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+	f.UseSection("prod")
+	cfg.AddSource(f)
+	cfg.SetRuntimeOverride("bool1", "1")
+
+	assertExplain := func(name string, expected ...string) {
+		t.Helper()
+		actual := cfg.Explain(name)
+		if len(actual) != len(expected) {
+			t.Errorf("Expected Explain(%q) to return %d results, instead found %d: %v", name, len(expected), len(actual), actual)
+			return
+		}
+		for n := range actual {
+			if actual[n].String() != expected[n] {
+				t.Errorf("Unexpected result[%d] from Explain(%q): expected %q, found %q", n, name, expected[n], actual[n].String())
+			}
+		}
+	}
+	assertExplain("visible", "  default value: ", "* /tmp/fake.cnf line 4 [prod]: prod")
+	assertExplain("hasshort", "  default value: ", "  /tmp/fake.cnf line 1: file", "* command line: cli")
+	assertExplain("bool1", "  default value: ", "* runtime override: 1")
+	assertExplain("required", "  default value: ", "* command line: arg1")
+	if p := cfg.Explain("visible")[1]; p.Source != f || p.Section != "prod" || p.Value != "prod" || !p.Won {
+		t.Errorf("Unexpected fields in Provenance: %+v", p)
+	}
+
+	var b strings.Builder
+	if err := cfg.Dump(&b); err != nil {
+		t.Fatalf("Unexpected error from Dump: %v", err)
+	}
+	expected := "hasshort = cli\n    default value: \n    /tmp/fake.cnf line 1: file\n  * command line: cli\nhelp = \n"
+	if actual := b.String(); !strings.Contains(actual, expected) {
+		t.Errorf("Output of Dump did not contain expected substring %q; full output:\n%s", expected, actual)
+	}
+}
+
+func TestPrintConfigOption(t *testing.T) {
+	suite := simpleCommandSuite()
+	suite.AddPrintConfigOption()
+	suite.SubCommands["one"].Handler = func(*Config) error {
+		return errors.New("handler should not be called")
+	}
+	suite.SubCommands["one"].AddOption(IntOption("count", 0, 0, "dummy description"))
+
+	// Replace STDOUT with a pipe to capture the output
+	origStdout := os.Stdout
+	defer func() {
+		os.Stdout = origStdout
+	}()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("Unable to create pipe: %v", err)
+	}
+	os.Stdout = w
+	cfg := ParseFakeCLI(t, suite, "mycommand one --print-config --count=abc")
+	if err := cfg.HandleCommand(); err != nil {
+		t.Errorf("Unexpected error from HandleCommand: %v", err)
+	}
+	w.Close()
+	output, _ := io.ReadAll(r)
+	if !strings.Contains(string(output), "count = abc\n    default value: 0\n  * command line: abc\n") {
+		t.Errorf("Unexpected output from --print-config: %s", output)
+	}
+
+	// Without the option, the handler should run normally, after validation
+	cfg = ParseFakeCLI(t, suite, "mycommand one --count=abc")
+	if err := cfg.HandleCommand(); err == nil || strings.Contains(err.Error(), "handler") {
+		t.Errorf("Expected validation error from HandleCommand, instead found %v", err)
+	}
+	cfg = ParseFakeCLI(t, suite, "mycommand one --skip-print-config")
+	if err := cfg.HandleCommand(); err == nil || !strings.Contains(err.Error(), "handler") {
+		t.Errorf("Expected handler error from HandleCommand, instead found %v", err)
+	}
+	if opt := suite.SubCommands["one"].Options()["print-config"]; opt == nil || !opt.HiddenOnCLI {
+		t.Error("Expected print-config to be a hidden option inherited by subcommands")
+	}
+}
@@ -2,16 +2,20 @@ package mybase
 
 import (
 	"bufio"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
+	"maps"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
-	"sort"
+	"slices"
 	"strings"
+	"time"
 	"unicode"
 )
 
@@ -19,26 +23,63 @@ import (
 // precede any named section are still associated with a Section object, but
 // with a Name of "".
 type Section struct {
-	Name   string
-	Values map[string]string  // mapping of option name => value as string
-	opts   map[string]*Option // mapping of option name => option definition
+	Name      string
+	Parent    string                    // name of the section's parent, if declared using "[name : parent]" syntax
+	Values    map[string]string         // mapping of option name => value as string
+	opts      map[string]*Option        // mapping of option name => option definition
+	lines     map[string]*fileLine      // mapping of option name => last line of the file itself (not an included file) which set the value
+	persisted map[string]string         // values as of the most recent Parse or Write, to determine which lines need rewriting
+	locations map[string]OptionLocation // mapping of option name => where the value was set, possibly in an included file
+	repeated  map[string][]string       // mapping of option name => all values in order, for options marked as Repeatable
+	parentLoc OptionLocation            // where Parent was declared, for purposes of error messages
+}
+
+// OptionLocation describes where an option value was set in an option file.
+type OptionLocation struct {
+	FilePath   string // Path of the file containing the value, which may be a file included by another file
+	Section    string // Name of the section containing the value, or "" for the default section
+	LineNumber int    // Line number containing the value, starting from 1
+	RawKey     string // Option name as written, including any prefix such as "loose-" or "skip-"
+}
+
+// String returns the location's file path and line number, for example
+// "/etc/app.cnf line 12".
+func (loc OptionLocation) String() string {
+	return fmt.Sprintf("%s line %d", loc.FilePath, loc.LineNumber)
+}
+
+// fileStat tracks the modification time and size of a file or directory which
+// was read from disk in order to load an option file, for purposes of
+// detecting subsequent changes. For files, a hash of the contents is also
+// tracked, since an edit which does not change the size may occur within the
+// filesystem's modification time granularity.
+type fileStat struct {
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte // zero value for directories
 }
 
 // File represents a form of ini-style option file. Lines can contain
 // [sections], option=value, option without value (usually for bools), or
-// comments.
+// comments. Other file syntaxes are supported via the FileFormat interface.
 type File struct {
 	Dir                  string
 	Name                 string
 	IgnoreUnknownOptions bool
+	Format               FileFormat // Syntax of the file; if nil, determined by the file's extension as per FileFormat, defaulting to ini syntax
 	sections             []*Section
 	sectionIndex         map[string]*Section
 	read                 bool
 	parsed               bool
 	contents             string
-	selected             []string
+	selected             []string // names of sections used by OptionValue, in priority order, after expanding parents and patterns
+	useSectionNames      []string // names most recently passed to UseSection, so that reloads can expand them again
 	ignoredOptionNames   map[string]bool
 	onlyOptionNames      map[string]bool
+	includedFiles        []string            // paths of files pulled in via !include or !includedir
+	lines                []*fileLine         // lines of the file itself, as of the most recent Parse or Write
+	stats                map[string]fileStat // mapping of path => stat, for the file itself and anything it included, as of the most recent Read or Parse
+	skippedKeys          []string            // keys of unknown, loose, or ignored options skipped by the most recent Parse
 }
 
 // NewFile returns a value representing an option file. The arg(s) will be
@@ -52,9 +93,12 @@ func NewFile(paths ...string) *File {
 	}
 
 	defaultSection := &Section{
-		Name:   "",
-		Values: make(map[string]string),
-		opts:   make(map[string]*Option),
+		Name:      "",
+		Values:    make(map[string]string),
+		opts:      make(map[string]*Option),
+		lines:     make(map[string]*fileLine),
+		locations: make(map[string]OptionLocation),
+		repeated:  make(map[string][]string),
 	}
 
 	return &File{
@@ -84,51 +128,51 @@ func (f *File) String() string {
 
 // Write writes out the file's contents to disk. If overwrite=false and the
 // file already exists, an error will be returned.
-// Note that if overwrite=true and the file already exists, any comments
-// and extra whitespace in the file will be lost upon re-writing. All option
-// names and values will be normalized in the rewritten file. Any "loose-"
-// prefix option names that did not exist will not be written, and any that
-// did exist will have their "loose-" prefix stripped. These shortcomings will
-// be fixed in a future release.
+// If the file uses a FileFormat, the entire file is rewritten using the
+// format's Format method, which only has access to recognized option values.
+// Comments are discarded, and an error is returned without writing anything if
+// Parse skipped any unknown, loose, or ignored options, since these would
+// otherwise be silently lost. For ini files, if the file was previously parsed,
+// only lines corresponding to options that have since been changed via
+// SetOptionValue or UnsetOptionValue are modified.
+// Comments, whitespace, ordering, sections, include directives, and the exact
+// formatting of unchanged lines are all preserved. Changed values are updated
+// in-place, removed values have their lines deleted, and newly-added values
+// are written at the end of their section, or in a new section at the end of
+// the file.
 func (f *File) Write(overwrite bool) error {
-	lines := make([]string, 0)
-	for n, section := range f.sections {
-		if section.Name != "" {
-			lines = append(lines, fmt.Sprintf("[%s]", section.Name))
-		}
-
-		ks := make([]string, 0, len(section.Values))
-		for k := range section.Values {
-			ks = append(ks, k)
-		}
-		sort.Strings(ks)
-		for _, k := range ks {
-			// Note: section.opts[k] will be nil if the option value came from
-			// File.SetOptionValue() and was not previously set! In this case we always
-			// treat the opt as stringy, to avoid converting some-int=0 to skip-some-int
-			optionIsBoolean := (section.opts[k] != nil && section.opts[k].Type == OptionTypeBool)
-			val := section.Values[k]
-			if (optionIsBoolean && !BoolValue(val)) || val == "''" { // false-valued boolean, or explicitly-empty-string non-boolean
-				lines = append(lines, fmt.Sprintf("skip-%s", k))
-			} else if optionIsBoolean || val == "" { // true-valued boolean, or valueless (implying value-optional) non-boolean
-				lines = append(lines, k)
-			} else { // non-boolean with a value
-				lines = append(lines, fmt.Sprintf("%s=%s", k, val))
-			}
+	var contents string
+	if format := f.format(); format != nil {
+		if len(f.skippedKeys) > 0 {
+			return fmt.Errorf("Unable to rewrite %s: doing so would discard unrecognized or ignored options %s", f.Path(), strings.Join(slices.Compact(slices.Sorted(slices.Values(f.skippedKeys))), ", "))
 		}
-
-		// Append a blank line after the section, unless it was the last one, or
-		// it was the default section and had no values
-		if n < len(f.sections)-1 && (section.Name != "" || len(section.Values) > 0) {
-			lines = append(lines, "")
+		var err error
+		if contents, err = format.Format(f.sections); err != nil {
+			return err
 		}
+	} else {
+		f.lines = f.rewrittenLines()
+		lines := make([]string, len(f.lines))
+		for n, line := range f.lines {
+			lines[n] = line.text
+		}
+		if len(lines) == 0 {
+			log.Printf("Skipping write to %s due to empty configuration", f.Path())
+			return nil
+		}
+		newline, bom := "\n", ""
+		if strings.Contains(f.contents, "\r\n") {
+			newline = "\r\n"
+		}
+		if strings.HasPrefix(f.contents, "\uFEFF") {
+			bom = "\uFEFF"
+		}
+		contents = bom + strings.Join(lines, newline) + newline
 	}
-
-	if len(lines) == 0 {
-		log.Printf("Skipping write to %s due to empty configuration", f.Path())
-		return nil
+	for _, section := range f.sections {
+		section.persisted = maps.Clone(section.Values)
 	}
-	f.contents = fmt.Sprintf("%s\n", strings.Join(lines, "\n"))
+	f.contents = contents
 	f.read = true
 	f.parsed = true
 
@@ -149,9 +193,143 @@ func (f *File) Write(overwrite bool) error {
 	if err1 := osFile.Close(); err == nil {
 		err = err1
 	}
+	if err == nil {
+		f.recordStat(f.Path(), []byte(f.contents))
+	}
 	return err
 }
 
+// rewrittenLines returns a new list of lines for the file, reflecting any
+// changes to option values since the most recent Parse or Write.
+func (f *File) rewrittenLines() []*fileLine {
+	removed := make(map[*fileLine]bool)
+	added := make(map[*Section][]*fileLine)
+	for _, section := range f.sections {
+		keys := slices.Sorted(maps.Keys(section.Values))
+		for key := range section.persisted {
+			if _, stillSet := section.Values[key]; !stillSet {
+				keys = append(keys, key)
+			}
+		}
+		for _, key := range keys {
+			value, isSet := section.Values[key]
+			if prevValue, wasSet := section.persisted[key]; isSet && wasSet && value == prevValue {
+				continue
+			}
+			if !isSet {
+				// Remove all lines setting this option in this section, not just the
+				// last one, since otherwise an earlier line would take effect
+				for _, line := range f.optionLines(section, key) {
+					removed[line] = true
+				}
+				delete(section.lines, key)
+			} else if line := section.lines[key]; line != nil {
+				line.text = rewriteOptionLine(line.text, key, value, section.opts[key])
+				if opt := section.opts[key]; opt != nil && opt.repeatable {
+					// Earlier lines would otherwise still contribute values to GetAll
+					for _, other := range f.optionLines(section, key) {
+						removed[other] = other != line
+					}
+				}
+			} else if lines := f.optionLines(section, key); len(lines) > 0 {
+				// Dotted-key lines which each set part of a map option's value are
+				// replaced by a single line setting the entire value
+				lines[0].text = formatOptionLine(key, value, section.opts[key])
+				for _, other := range lines[1:] {
+					removed[other] = true
+				}
+				section.lines[key] = lines[0]
+			} else {
+				line := &fileLine{
+					text:    formatOptionLine(key, value, section.opts[key]),
+					kind:    lineTypeKeyValue,
+					section: section,
+					key:     key,
+				}
+				added[section] = append(added[section], line)
+				section.lines[key] = line
+			}
+		}
+	}
+
+	// Determine where new lines should be inserted for each section: after the
+	// last non-blank, non-comment line of the section. If the default section has
+	// no such line, insert prior to the first section header, excluding any
+	// comment block attached to that header.
+	lines := make([]*fileLine, 0, len(f.lines))
+	for _, line := range f.lines {
+		if !removed[line] {
+			lines = append(lines, line)
+		}
+	}
+	insertAfter := make(map[*Section]int)
+	firstHeader := len(lines)
+	for n, line := range lines {
+		if line.kind == lineTypeSectionHeader && firstHeader == len(lines) {
+			firstHeader = n
+		}
+		if line.kind != lineTypeBlank && line.kind != lineTypeComment {
+			insertAfter[line.section] = n
+		}
+	}
+	defaultSection := f.sectionIndex[""]
+	if _, ok := insertAfter[defaultSection]; !ok && len(added[defaultSection]) > 0 {
+		pos := firstHeader
+		for pos > 0 && lines[pos-1].kind == lineTypeComment {
+			pos--
+		}
+		for pos > 0 && lines[pos-1].kind == lineTypeBlank {
+			pos--
+		}
+		if pos < len(lines) && lines[pos].kind != lineTypeBlank {
+			added[defaultSection] = append(added[defaultSection], &fileLine{kind: lineTypeBlank, section: defaultSection})
+		}
+		insertAfter[defaultSection] = pos - 1
+	}
+
+	result := make([]*fileLine, 0, len(lines))
+	for n := -1; n < len(lines); n++ {
+		if n >= 0 {
+			result = append(result, lines[n])
+		}
+		for _, section := range f.sections {
+			if pos, ok := insertAfter[section]; ok && pos == n {
+				result = append(result, added[section]...)
+			}
+		}
+	}
+
+	// Any new sections go at the end of the file
+	for _, section := range f.sections {
+		if _, exists := insertAfter[section]; exists || len(added[section]) == 0 {
+			continue
+		}
+		if len(result) > 0 && result[len(result)-1].kind != lineTypeBlank {
+			result = append(result, &fileLine{kind: lineTypeBlank, section: section})
+		}
+		header := &fileLine{
+			text:    fmt.Sprintf("[%s]", section.Name),
+			kind:    lineTypeSectionHeader,
+			section: section,
+		}
+		result = append(result, header)
+		result = append(result, added[section]...)
+	}
+	return result
+}
+
+// optionLines returns all lines of the file itself which set a value for the
+// supplied option name in section, in order.
+func (f *File) optionLines(section *Section, key string) []*fileLine {
+	var result []*fileLine
+	for _, line := range f.lines {
+		if line.section == section && line.key == key {
+			result = append(result, line)
+		}
+	}
+	return result
+}
+
 // Read loads the contents of the option file, but does not parse it.
 func (f *File) Read() error {
 	file, err := os.Open(f.Path())
@@ -159,17 +337,89 @@ func (f *File) Read() error {
 		return err
 	}
 	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
 	bytes, err := ioutil.ReadAll(file)
 	if err != nil {
 		return err
 	}
 	f.contents = string(bytes)
 	f.read = true
+	f.stats = map[string]fileStat{
+		f.Path(): {modTime: info.ModTime(), size: info.Size(), hash: sha256.Sum256(bytes)},
+	}
 	return nil
 }
 
+// recordStat stores the current modification time and size of path, which
+// should be f itself or a file or directory included by f. For files, contents
+// should be what was just read from or written to path; for directories, it
+// should be nil.
+func (f *File) recordStat(path string, contents []byte) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+	if f.stats == nil {
+		f.stats = make(map[string]fileStat)
+	}
+	stat := fileStat{modTime: info.ModTime(), size: info.Size()}
+	if !info.IsDir() {
+		stat.hash = sha256.Sum256(contents)
+	}
+	f.stats[path] = stat
+}
+
+// changedOnDisk returns true if the modification time or size of f, or of any
+// file or directory included by f, differs from when it was last read. If
+// these are unchanged, files are re-read to determine whether their contents
+// differ. If f was never read from disk, false is returned.
+func (f *File) changedOnDisk() bool {
+	for path, prev := range f.stats {
+		info, err := os.Stat(path)
+		if err != nil || !info.ModTime().Equal(prev.modTime) || info.Size() != prev.size {
+			return true
+		}
+		if !info.IsDir() {
+			contents, err := os.ReadFile(path)
+			if err != nil || sha256.Sum256(contents) != prev.hash {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// reloaded returns a new File with the same path and settings as f, after
+// reading and parsing it from disk. The same section names are passed to
+// UseSection as were passed for f, so that any changes to section parents or
+// pattern sections take effect. Names of sections which no longer exist are
+// ignored.
+// f itself is not modified.
+func (f *File) reloaded(cfg *Config) (*File, error) {
+	nf := NewFile(f.Dir, f.Name)
+	nf.IgnoreUnknownOptions = f.IgnoreUnknownOptions
+	nf.Format = f.Format
+	nf.ignoredOptionNames = maps.Clone(f.ignoredOptionNames)
+	nf.onlyOptionNames = maps.Clone(f.onlyOptionNames)
+	if err := nf.Parse(cfg); err != nil {
+		return nil, err
+	}
+	nf.UseSection(f.useSectionNames...)
+	return nf, nil
+}
+
 // Parse parses the file contents into a series of Sections. A Config object
 // must be supplied so that the list of valid Options is known.
+// Lines of form "!include /path/to/file" or "!includedir /path/to/dir" cause
+// other option files to be parsed at that point, as with MySQL option files.
+// Values from included files are stored in f, beginning in whichever section
+// was active at the point of the directive. Relative paths are interpreted
+// based on the directory containing the file with the directive. Directories
+// are only searched for files with a .cnf or .ini extension, which are parsed
+// in order by name.
 func (f *File) Parse(cfg *Config) error {
 	if !f.read {
 		if err := f.Read(); err != nil {
@@ -177,10 +427,100 @@ func (f *File) Parse(cfg *Config) error {
 		}
 	}
 
-	section := f.sectionIndex[""]
+	contents := strings.TrimPrefix(f.contents, "\uFEFF") // strip utf8 BOM if present
+	f.lines = nil
+	f.skippedKeys = nil
+	if format := f.format(); format != nil {
+		if err := f.parseEntries(cfg, format, contents); err != nil {
+			return err
+		}
+	} else if err := f.parseContents(cfg, f.Path(), contents, f.sectionIndex[""], []string{f.Path()}); err != nil {
+		return err
+	} else if err := f.checkParents(); err != nil {
+		return err
+	}
+	for _, section := range f.sections {
+		section.persisted = maps.Clone(section.Values)
+	}
+
+	f.parsed = true
+	f.selected = []string{""}
+	f.useSectionNames = nil
+	return nil
+}
+
+// checkParents verifies that the parent of every section exists, and that
+// there are no cycles of sections inheriting from each other.
+func (f *File) checkParents() error {
+	for _, section := range f.sections {
+		if section.Parent == "" {
+			continue
+		}
+		var problem string
+		if _, ok := f.sectionIndex[section.Parent]; !ok {
+			problem = fmt.Sprintf("Section %s has unknown parent section %s", section.Name, section.Parent)
+		} else if ancestry := f.ancestry(section.Name); f.sectionIndex[ancestry[len(ancestry)-1]].Parent == section.Name {
+			problem = fmt.Sprintf("Section inheritance cycle: %s -> %s", strings.Join(ancestry, " -> "), section.Name)
+		}
+		if problem != "" {
+			return FileParseFormatError{
+				Problem:    problem,
+				FilePath:   section.parentLoc.FilePath,
+				LineNumber: section.parentLoc.LineNumber,
+			}
+		}
+	}
+	return nil
+}
+
+// format returns the FileFormat used by f, or nil if f uses ini syntax.
+func (f *File) format() FileFormat {
+	if f.Format != nil {
+		return f.Format
+	}
+	return formatForExtension(f.Name)
+}
+
+// parseEntries handles parsing of f's contents using a FileFormat.
+func (f *File) parseEntries(cfg *Config, format FileFormat, contents string) error {
+	entries, err := format.Parse(contents)
+	if err != nil {
+		var fpfErr FileParseFormatError
+		if !errors.As(err, &fpfErr) {
+			fpfErr.Problem = err.Error()
+		}
+		fpfErr.FilePath = f.Path()
+		return fpfErr
+	}
+	for _, entry := range entries {
+		token := entry.Key
+		if entry.HasValue {
+			token += "=" + entry.Value
+		}
+		parsedLine := &parsedLine{
+			rawKey: entry.Key,
+			kind:   lineTypeKeyOnly,
+		}
+		var hasValue bool
+		parsedLine.key, parsedLine.value, hasValue, parsedLine.isLoose = NormalizeOptionToken(token)
+		if hasValue {
+			parsedLine.kind = lineTypeKeyValue
+		}
+		section := f.getOrCreateSection(entry.Section)
+		if err := f.parseOption(cfg, parsedLine, section, f.Path(), entry.LineNumber, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
+// parseContents handles parsing of contents, which came from the option file
+// at path. This is either f itself, or a file included from f (directly or
+// indirectly). Parsing begins in the supplied section. includeStack contains
+// the paths of all files currently being parsed, for purposes of detecting
+// include cycles.
+func (f *File) parseContents(cfg *Config, path, contents string, section *Section, includeStack []string) error {
 	var lineNumber int
-	contents := strings.TrimPrefix(f.contents, "\uFEFF") // strip utf8 BOM if present
 	scanner := bufio.NewScanner(strings.NewReader(contents))
 	for scanner.Scan() {
 		line := scanner.Text()
@@ -190,54 +530,223 @@ func (f *File) Parse(cfg *Config) error {
 		if err != nil {
 			return FileParseFormatError{
 				Problem:    err.Error(),
-				FilePath:   f.Path(),
+				FilePath:   path,
 				LineNumber: lineNumber,
 			}
 		}
+		if parsedLine.kind == lineTypeSectionHeader {
+			section = f.getOrCreateSection(parsedLine.sectionName)
+			if parsedLine.parentName != "" {
+				if section.Parent != "" && section.Parent != parsedLine.parentName {
+					return FileParseFormatError{
+						Problem:    fmt.Sprintf("Section %s was previously declared with parent section %s", section.Name, section.Parent),
+						FilePath:   path,
+						LineNumber: lineNumber,
+					}
+				}
+				section.Parent = parsedLine.parentName
+				section.parentLoc = OptionLocation{FilePath: path, Section: section.Name, LineNumber: lineNumber}
+			}
+		}
+
+		// Track lines of the file itself, but not lines of included files
+		var fl *fileLine
+		if len(includeStack) == 1 {
+			fl = &fileLine{
+				text:    line,
+				kind:    parsedLine.kind,
+				section: section,
+			}
+			f.lines = append(f.lines, fl)
+		}
 
 		switch parsedLine.kind {
-		case lineTypeSectionHeader:
-			section = f.getOrCreateSection(parsedLine.sectionName)
-		case lineTypeKeyOnly, lineTypeKeyValue:
-			if f.ignoredOptionNames[parsedLine.key] || (len(f.onlyOptionNames) > 0 && !f.onlyOptionNames[parsedLine.key]) {
-				continue
+		case lineTypeInclude, lineTypeIncludeDir:
+			if err := f.parseInclude(cfg, parsedLine, path, lineNumber, section, includeStack); err != nil {
+				return err
 			}
-			opt := cfg.FindOption(parsedLine.key)
-			if opt == nil {
-				if parsedLine.isLoose || f.IgnoreUnknownOptions || cfg.LooseFileOptions {
-					continue
-				} else {
-					return OptionNotDefinedError{parsedLine.key, fmt.Sprintf("%s line %d", f.Path(), lineNumber)}
-				}
+		case lineTypeKeyOnly, lineTypeKeyValue:
+			if err := f.parseOption(cfg, parsedLine, section, path, lineNumber, fl); err != nil {
+				return err
 			}
-			if parsedLine.kind == lineTypeKeyOnly {
-				if opt.RequireValue {
-					return OptionMissingValueError{opt.Name, fmt.Sprintf("%s line %d", f.Path(), lineNumber)}
-				} else if opt.Type == OptionTypeBool {
-					// For booleans, option without value indicates option is being enabled
-					parsedLine.value = "1"
-				}
-			} else if parsedLine.value == "" && opt.Type == OptionTypeString {
-				// Convert empty strings into quote-wrapped empty strings, so that callers
-				// may differentiate between bare "foo" vs "foo=" if desired, by using
-				// Config.GetRaw(). Meanwhile Config.Get and most other getters strip
-				// surrounding quotes, so this does not break anything.
-				parsedLine.value = "''"
+		}
+	}
+	return scanner.Err()
+}
+
+// parseOption handles a line which sets an option value, which came from the
+// option file at path on the supplied line number. fl should be the
+// corresponding line of f itself, or nil if the value came from an included
+// file or a FileFormat which does not support rewriting individual lines.
+func (f *File) parseOption(cfg *Config, parsedLine *parsedLine, section *Section, path string, lineNumber int, fl *fileLine) error {
+	folded := foldDottedKey(cfg, parsedLine, section)
+	if f.ignoredOptionNames[parsedLine.key] || (len(f.onlyOptionNames) > 0 && !f.onlyOptionNames[parsedLine.key]) {
+		f.skippedKeys = append(f.skippedKeys, parsedLine.rawKey)
+		return nil
+	}
+	opt := cfg.FindOption(parsedLine.key)
+	if opt == nil {
+		if parsedLine.isLoose || f.IgnoreUnknownOptions || cfg.LooseFileOptions {
+			f.skippedKeys = append(f.skippedKeys, parsedLine.rawKey)
+			return nil
+		} else {
+			return OptionNotDefinedError{parsedLine.key, fmt.Sprintf("%s line %d", path, lineNumber)}
+		}
+	}
+	if parsedLine.kind == lineTypeKeyOnly {
+		if opt.RequireValue {
+			return OptionMissingValueError{opt.Name, fmt.Sprintf("%s line %d", path, lineNumber)}
+		} else if opt.Type == OptionTypeBool {
+			// For booleans, option without value indicates option is being enabled
+			parsedLine.value = "1"
+		}
+	} else if parsedLine.value == "" && opt.Type == OptionTypeString {
+		// Convert empty strings into quote-wrapped empty strings, so that callers
+		// may differentiate between bare "foo" vs "foo=" if desired, by using
+		// Config.GetRaw(). Meanwhile Config.Get and most other getters strip
+		// surrounding quotes, so this does not break anything.
+		parsedLine.value = "''"
+	}
+	value, err := opt.ingestValue(parsedLine.value, f, fmt.Sprintf("%s line %d", path, lineNumber))
+	if err != nil {
+		return err
+	}
+	section.Values[parsedLine.key] = value
+	section.opts[parsedLine.key] = opt
+	if opt.repeatable {
+		section.repeated[parsedLine.key] = append(section.repeated[parsedLine.key], value)
+	}
+	section.locations[parsedLine.key] = OptionLocation{
+		FilePath:   path,
+		Section:    section.Name,
+		LineNumber: lineNumber,
+		RawKey:     parsedLine.rawKey,
+	}
+	if fl != nil {
+		fl.key = parsedLine.key
+	}
+	if fl != nil && !folded {
+		section.lines[parsedLine.key] = fl
+		return nil
+	} else if fl == nil {
+		// A value from an included file overrides (or unsets, via a "skip-" prefix)
+		// any earlier lines of the file itself, so rewriting those lines would have
+		// no effect. Detach them, so that rewrittenLines appends a new line instead.
+		for _, line := range f.optionLines(section, parsedLine.key) {
+			line.key = ""
+		}
+	}
+	// Lines of included files cannot be rewritten to reflect a new value.
+	// Dotted-key lines only set part of the value, so rewrittenLines replaces
+	// all of them with a single line instead.
+	delete(section.lines, parsedLine.key)
+	return nil
+}
+
+// foldDottedKey handles lines of form "name.key=value", where name refers to an
+// option created by MapOption. If parsedLine is of this form, it is modified to
+// instead set the option's entire value, consisting of any value previously
+// set in the section with key=value appended, and true is returned. Otherwise
+// parsedLine is left as-is and false is returned.
+func foldDottedKey(cfg *Config, parsedLine *parsedLine, section *Section) bool {
+	name, _, ok := strings.Cut(parsedLine.key, ".")
+	if !ok || cfg.FindOption(parsedLine.key) != nil {
+		return false
+	}
+	if opt := cfg.FindOption(name); opt == nil || opt.ValueType != ValueTypeMap {
+		return false
+	}
+	_, mapKey, _ := strings.Cut(parsedLine.rawKey, ".")
+	pair := mapKey + "=" + quoteMapValue(parsedLine.value)
+	if prevValue := unquote(section.Values[name]); prevValue != "" {
+		pair = prevValue + "," + pair
+	}
+	parsedLine.key = name
+	parsedLine.value = pair
+	parsedLine.kind = lineTypeKeyValue
+	return true
+}
+
+// quoteMapValue returns value in a form which can be used as the value of a
+// key=value pair in a MapOption's value. If value is not already quote-wrapped
+// and contains delimiters, quotes, or backslashes, it is wrapped in double
+// quotes, with any double quotes or backslashes escaped.
+func quoteMapValue(value string) string {
+	if _, quote := trimQuotes(value); quote != 0 || !strings.ContainsAny(value, ",=\\'\"`") {
+		return value
+	}
+	replacer := strings.NewReplacer("\\", "\\\\", "\"", "\\\"")
+	return "\"" + replacer.Replace(value) + "\""
+}
+
+// parseInclude handles an !include or !includedir directive, which appeared in
+// the file at path on the supplied line number.
+func (f *File) parseInclude(cfg *Config, directive *parsedLine, path string, lineNumber int, section *Section, includeStack []string) error {
+	formatError := func(problem string) error {
+		return FileParseFormatError{
+			Problem:    problem,
+			FilePath:   path,
+			LineNumber: lineNumber,
+		}
+	}
+
+	target := directive.includePath
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	target = filepath.Clean(target)
+
+	var paths []string
+	if directive.kind == lineTypeInclude {
+		paths = []string{target}
+	} else {
+		entries, err := os.ReadDir(target) // sorted by filename
+		if err != nil {
+			return formatError(err.Error())
+		}
+		f.recordStat(target, nil) // detect files being added or removed
+		for _, entry := range entries {
+			ext := strings.ToLower(filepath.Ext(entry.Name()))
+			if !entry.IsDir() && (ext == ".cnf" || ext == ".ini") {
+				paths = append(paths, filepath.Join(target, entry.Name()))
 			}
-			section.Values[parsedLine.key] = parsedLine.value
-			section.opts[parsedLine.key] = opt
 		}
 	}
 
-	f.parsed = true
-	f.selected = []string{""}
-	return scanner.Err()
+	for _, includePath := range paths {
+		if slices.Contains(includeStack, includePath) {
+			return formatError("include cycle detected: " + strings.Join(append(includeStack, includePath), " -> "))
+		}
+		contents, err := os.ReadFile(includePath)
+		if err != nil {
+			return formatError(err.Error())
+		}
+		f.recordStat(includePath, contents)
+		f.includedFiles = append(f.includedFiles, includePath)
+		stack := append(slices.Clip(includeStack), includePath)
+		if err := f.parseContents(cfg, includePath, strings.TrimPrefix(string(contents), "\uFEFF"), section, stack); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // UseSection changes which section(s) of the file are used when calling
 // OptionValue. If multiple section names are supplied, multiple sections will
 // be checked by OptionValue, with sections listed first taking precedence over
 // subsequent ones.
+// If a section declares a parent section, using header syntax such as
+// "[production-eu : production]" (the colon must be surrounded by spaces), the
+// parent is checked after that section (and the parent's own parent after
+// that, and so on), prior to any subsequent sections supplied to this function.
+// A section name in the file may also be a glob pattern, using header syntax
+// such as "[production-*]"; see path.Match for pattern syntax. Such a section
+// is used whenever a name supplied to this function matches the pattern. For
+// each supplied name, a section with exactly that name takes precedence over
+// any pattern sections matching the name, and more specific patterns (those
+// with more literal, non-wildcard characters) take precedence over less
+// specific ones. Patterns with equal specificity are checked in the order they
+// appear in the file.
 // Note that the default nameless section "" (i.e. lines at the top of the file
 // prior to a section header) is automatically appended to the end of the list.
 // So this section is always checked, at lowest priority, need not be
@@ -250,20 +759,29 @@ func (f *File) UseSection(names ...string) error {
 	// if there are other shallow copies of f, calling UseSection on one won't
 	// affect the others.
 	f.selected = make([]string, 0, len(names)+1)
+	f.useSectionNames = slices.Clone(names)
 
 	for _, name := range names {
 		if already[name] {
 			continue
 		}
-		already[name] = true
-		if f.HasSection(name) {
-			f.selected = append(f.selected, name)
-		} else {
+		matches := f.sectionsMatching(name)
+		if len(matches) == 0 {
+			already[name] = true
 			notFound = append(notFound, name)
+			continue
+		}
+		for _, match := range matches {
+			for _, ancestor := range f.ancestry(match) {
+				if !already[ancestor] {
+					already[ancestor] = true
+					f.selected = append(f.selected, ancestor)
+				}
+			}
 		}
 	}
 	if !already[""] {
-		f.selected = append(names, "")
+		f.selected = append(f.selected, "")
 	}
 
 	if len(notFound) == 0 {
@@ -272,10 +790,82 @@ func (f *File) UseSection(names ...string) error {
 	return fmt.Errorf("File %s missing section: %s", f.Path(), strings.Join(notFound, ", "))
 }
 
-// HasSection returns true if the file has a section with the supplied name.
+// ancestry returns the supplied section name, followed by the names of its
+// parent, grandparent, etc. The default section "" is not included unless name
+// is "". Sections which do not exist are not included.
+func (f *File) ancestry(name string) []string {
+	var result []string
+	for section := f.sectionIndex[name]; section != nil && !slices.Contains(result, section.Name); section = f.sectionIndex[section.Parent] {
+		result = append(result, section.Name)
+		if section.Parent == "" {
+			break
+		}
+	}
+	return result
+}
+
+// sectionsMatching returns the names of sections which UseSection should select
+// for the supplied name: the section with exactly that name, if any, followed by
+// any pattern sections matching the name, from most to least specific.
+func (f *File) sectionsMatching(name string) []string {
+	var result []string
+	if _, ok := f.sectionIndex[name]; ok {
+		result = append(result, name)
+	}
+	if name == "" {
+		return result
+	}
+	var patterns []string
+	for _, section := range f.sections {
+		if section.Name != name && isSectionPattern(section.Name) {
+			if matched, _ := path.Match(section.Name, name); matched {
+				patterns = append(patterns, section.Name)
+			}
+		}
+	}
+	slices.SortStableFunc(patterns, func(a, b string) int {
+		return patternSpecificity(b) - patternSpecificity(a)
+	})
+	return append(result, patterns...)
+}
+
+// isSectionPattern returns true if the supplied section name contains glob
+// metacharacters and is a well-formed pattern as per path.Match. Malformed
+// patterns are treated as literal section names.
+func isSectionPattern(name string) bool {
+	if !strings.ContainsAny(name, "*?[") {
+		return false
+	}
+	_, err := path.Match(name, "")
+	return err == nil
+}
+
+// patternSpecificity returns the number of literal characters in a glob
+// pattern, excluding wildcards and character classes.
+func patternSpecificity(pattern string) (count int) {
+	for n := 0; n < len(pattern); n++ {
+		switch pattern[n] {
+		case '*', '?': // wildcards do not count
+		case '[':
+			for n++; n < len(pattern) && pattern[n] != ']'; n++ {
+				if pattern[n] == '\\' {
+					n++
+				}
+			}
+		case '\\':
+			n++
+			count++
+		default:
+			count++
+		}
+	}
+	return count
+}
+
+// HasSection returns true if the file has a section with the supplied name, or
+// a pattern section (such as "[production-*]") which matches the supplied name.
 func (f *File) HasSection(name string) bool {
-	_, ok := f.sectionIndex[name]
-	return ok
+	return len(f.sectionsMatching(name)) > 0
 }
 
 // SectionsWithOption returns a list of section names that set the supplied
@@ -290,6 +880,22 @@ func (f *File) SectionsWithOption(optionName string) []string {
 	return result
 }
 
+// EffectiveSectionsWithOption is like SectionsWithOption, but also includes
+// sections which inherit a value for the supplied option name from a parent
+// section, as declared using header syntax such as "[name : parent]".
+func (f *File) EffectiveSectionsWithOption(optionName string) []string {
+	result := make([]string, 0, len(f.sections))
+	for _, section := range f.sections {
+		for _, name := range f.ancestry(section.Name) {
+			if _, ok := f.sectionIndex[name].Values[optionName]; ok {
+				result = append(result, section.Name)
+				break
+			}
+		}
+	}
+	return result
+}
+
 // SomeSectionHasOption returns true if at least one section sets the supplied
 // option name.
 func (f *File) SomeSectionHasOption(optionName string) bool {
@@ -311,6 +917,19 @@ func (f *File) SectionValues(name string) map[string]string {
 	return result
 }
 
+// EffectiveSectionValues is like SectionValues, but also includes values
+// inherited from the section's parent, grandparent, etc., as declared using
+// header syntax such as "[name : parent]". Values set directly in a section
+// take precedence over inherited values. The default section "" is not
+// considered to be a parent, so its values are not included unless name is "".
+func (f *File) EffectiveSectionValues(name string) map[string]string {
+	result := map[string]string{}
+	for _, ancestor := range slices.Backward(f.ancestry(name)) {
+		maps.Copy(result, f.sectionIndex[ancestor].Values)
+	}
+	return result
+}
+
 // OptionValue returns the value for the requested option from the option file.
 // Only the previously-selected section(s) of the file will be used, or the
 // default section "" if no section has been selected via UseSection.
@@ -333,6 +952,74 @@ func (f *File) OptionValue(optionName string) (string, bool) {
 	return "", false
 }
 
+// AllOptionValues returns every value for the requested option from the
+// option file, using the same section selection logic as OptionValue. Values
+// are ordered from lowest-priority section to highest-priority section, and
+// within each section, in the order they appear in the file. Only options
+// marked as Repeatable retain more than one value per section. This satisfies
+// the MultiOptionValuer interface.
+func (f *File) AllOptionValues(optionName string) ([]string, bool) {
+	if !f.parsed {
+		panic(fmt.Errorf("Call to AllOptionValues(\"%s\") on unparsed file %s", optionName, f.Path()))
+	}
+	var values []string
+	var found bool
+	for _, sectionName := range slices.Backward(f.selected) {
+		section := f.sectionIndex[sectionName]
+		if section == nil {
+			continue
+		}
+		if repeated, ok := section.repeated[optionName]; ok {
+			values = append(values, repeated...)
+			found = true
+		} else if value, ok := section.Values[optionName]; ok {
+			values = append(values, value)
+			found = true
+		}
+	}
+	return values, found
+}
+
+// DescribeSource returns the path and line number which supplied the value for
+// the requested option, for example "/etc/app.cnf line 12". The path may refer
+// to an included file. If the value was not set by a line of the file, for
+// example due to a call to SetOptionValue, only f's path is returned. This
+// satisfies the SourceDescriber interface.
+func (f *File) DescribeSource(optionName string) string {
+	if loc, ok := f.OptionLocation(optionName); ok {
+		return loc.String()
+	}
+	return f.Path()
+}
+
+// OptionLocation returns information on where the value for the requested
+// option was set, using the same section selection logic as OptionValue. The
+// boolean return value is false if no selected section has a value for the
+// option, or if the value was not set by a line of the file, for example due
+// to a call to SetOptionValue.
+func (f *File) OptionLocation(optionName string) (OptionLocation, bool) {
+	if section := f.valueSection(optionName); section != nil {
+		loc, ok := section.locations[optionName]
+		return loc, ok
+	}
+	return OptionLocation{}, false
+}
+
+// valueSection returns the highest-priority selected section which has a value
+// for the supplied option name, or nil if no selected section has a value.
+func (f *File) valueSection(optionName string) *Section {
+	for _, sectionName := range f.selected {
+		section := f.sectionIndex[sectionName]
+		if section == nil {
+			continue
+		}
+		if _, ok := section.Values[optionName]; ok {
+			return section
+		}
+	}
+	return nil
+}
+
 // SetOptionValue sets an option value in the named section. This is not
 // persisted to the file until Write is called on the File. This is not
 // guaranteed to affect any Config that is already using the File as a
@@ -340,6 +1027,8 @@ func (f *File) OptionValue(optionName string) (string, bool) {
 func (f *File) SetOptionValue(sectionName, optionName, value string) {
 	section := f.getOrCreateSection(sectionName)
 	section.Values[optionName] = value
+	delete(section.locations, optionName)
+	delete(section.repeated, optionName)
 }
 
 // UnsetOptionValue removes an option value in the named section. This is not
@@ -349,6 +1038,8 @@ func (f *File) SetOptionValue(sectionName, optionName, value string) {
 func (f *File) UnsetOptionValue(sectionName, optionName string) {
 	section := f.getOrCreateSection(sectionName)
 	delete(section.Values, optionName)
+	delete(section.locations, optionName)
+	delete(section.repeated, optionName)
 }
 
 // SameContents returns true if f and other have the same sections and values.
@@ -367,7 +1058,7 @@ func (f *File) SameContents(other *File) bool {
 	for name := range f.sectionIndex {
 		a := f.sectionIndex[name]
 		b, ok := other.sectionIndex[name]
-		if !ok || a.Name != b.Name {
+		if !ok || a.Name != b.Name || a.Parent != b.Parent {
 			return false
 		}
 		if !reflect.DeepEqual(a.Values, b.Values) {
@@ -380,8 +1071,8 @@ func (f *File) SameContents(other *File) bool {
 // IgnoreOptions causes the supplied option names to be ignored by a subsequent
 // call to Parse. The supplied option names do not need to exist as valid
 // options.
-// Note that if the file is later re-written, ignored options will be stripped
-// from the rewritten version.
+// Note that if the file is later re-written, lines containing ignored options
+// are left as-is in the rewritten version.
 // Panics if the file has already been parsed, as this would indicate a bug.
 func (f *File) IgnoreOptions(names ...string) {
 	if f.parsed {
@@ -399,8 +1090,8 @@ func (f *File) IgnoreOptions(names ...string) {
 // This method does not verify the existence of the supplied option names, but
 // they should exist as valid options, since they will be processed if
 // encountered in the file.
-// Note that if the file is later re-written, ignored options will be stripped
-// from the rewritten version.
+// Note that if the file is later re-written, lines containing ignored options
+// are left as-is in the rewritten version.
 // Panics if the file has already been parsed, as this would indicate a bug.
 func (f *File) LimitOptions(names ...string) {
 	if f.parsed {
@@ -435,15 +1126,110 @@ func (f *File) getOrCreateSection(name string) *Section {
 		return s
 	}
 	s := &Section{
-		Name:   name,
-		Values: make(map[string]string),
-		opts:   make(map[string]*Option),
+		Name:      name,
+		Values:    make(map[string]string),
+		opts:      make(map[string]*Option),
+		lines:     make(map[string]*fileLine),
+		locations: make(map[string]OptionLocation),
+		repeated:  make(map[string][]string),
 	}
 	f.sections = append(f.sections, s)
 	f.sectionIndex[name] = s
 	return s
 }
 
+// fileLine represents a single line of an option file, tracked for purposes of
+// rewriting the file without losing comments or formatting.
+type fileLine struct {
+	text    string
+	kind    lineType
+	section *Section // section containing the line
+	key     string   // option name, only set if the line's value was stored in section
+}
+
+// formatOptionLine returns a new option file line setting key to value.
+func formatOptionLine(key, value string, opt *Option) string {
+	// Note: opt will be nil if the option value came from File.SetOptionValue()
+	// and was not previously set! In this case we always treat the opt as
+	// stringy, to avoid converting some-int=0 to skip-some-int
+	optionIsBoolean := (opt != nil && opt.Type == OptionTypeBool)
+	if (optionIsBoolean && !BoolValue(value)) || value == "''" { // false-valued boolean, or explicitly-empty-string non-boolean
+		return "skip-" + key
+	} else if optionIsBoolean || value == "" { // true-valued boolean, or valueless (implying value-optional) non-boolean
+		return key
+	}
+	return key + "=" + value // non-boolean with a value
+}
+
+// rewriteOptionLine returns a modified version of an existing option file
+// line, changing its value. Indentation, inline comments, "loose-" prefix, and
+// the original spelling of the option name are preserved. If the line was
+// already of form key=value and remains so, whitespace around the equals sign
+// is also preserved.
+func rewriteOptionLine(line, key, value string, opt *Option) string {
+	indent := line[:len(line)-len(strings.TrimLeftFunc(line, unicode.IsSpace))]
+	body, comment := splitInlineComment(line[len(indent):])
+	rawKey, _, hasValue := strings.Cut(body, "=")
+	rawKey = strings.TrimSpace(rawKey)
+
+	var loosePrefix string
+	if normalized := strings.ReplaceAll(strings.ToLower(rawKey), "_", "-"); strings.HasPrefix(normalized, "loose-") {
+		loosePrefix, rawKey = rawKey[:6], rawKey[6:]
+	}
+	var negated bool
+	for _, prefix := range []string{"skip-", "disable-", "enable-"} {
+		if normalized := strings.ReplaceAll(strings.ToLower(rawKey), "_", "-"); strings.HasPrefix(normalized, prefix) {
+			rawKey = rawKey[len(prefix):]
+			negated = (prefix != "enable-")
+			break
+		}
+	}
+	if NormalizeOptionName(rawKey) != key {
+		rawKey = key
+	}
+
+	formatted := formatOptionLine(rawKey, value, opt)
+	if hasValue && !negated && strings.HasPrefix(formatted, rawKey+"=") {
+		eqIndex := strings.Index(body, "=")
+		after := body[eqIndex+1:]
+		spacing := after[:len(after)-len(strings.TrimLeftFunc(after, unicode.IsSpace))]
+		return indent + body[:eqIndex+1] + spacing + value + comment
+	}
+	return indent + loosePrefix + formatted + comment
+}
+
+// splitInlineComment splits an option line or directive line into its
+// key/value or directive portion (with trailing whitespace removed) and any
+// inline comment, which includes the whitespace preceding the comment. The
+// same quoting and escaping rules as parseLine are used to determine whether a
+// # begins a comment.
+func splitInlineComment(line string) (body, comment string) {
+	var inValue, escapeNext bool
+	var inQuote rune
+	for n, c := range line {
+		if escapeNext {
+			escapeNext = false
+			continue
+		}
+		switch {
+		case c == '#' && inQuote == 0:
+			body = strings.TrimRightFunc(line[:n], unicode.IsSpace)
+			return body, line[len(body):]
+		case !inValue:
+			inValue = (c == '=')
+		case c == '\'' || c == '"' || c == '`':
+			if c == inQuote {
+				inQuote = 0
+			} else if inQuote == 0 {
+				inQuote = c
+			}
+		case c == '\\':
+			escapeNext = true
+		}
+	}
+	return strings.TrimRightFunc(line, unicode.IsSpace), ""
+}
+
 type lineType int
 
 const (
@@ -452,15 +1238,20 @@ const (
 	lineTypeSectionHeader
 	lineTypeKeyOnly
 	lineTypeKeyValue
+	lineTypeInclude
+	lineTypeIncludeDir
 )
 
 type parsedLine struct {
 	sectionName string
+	parentName  string // name of parent section, for section headers of form "[name : parent]"
 	key         string
+	rawKey      string // key as written, prior to normalization
 	value       string
 	comment     string
 	kind        lineType
 	isLoose     bool
+	includePath string
 }
 
 // parseLine parses a file line into its components
@@ -478,6 +1269,30 @@ func parseLine(line string) (*parsedLine, error) {
 		return result, nil
 	}
 
+	if line[0] == '!' {
+		line, comment := splitInlineComment(line)
+		if _, after, found := strings.Cut(comment, "#"); found {
+			result.comment = after
+		}
+		directive, includePath := line, ""
+		if n := strings.IndexFunc(line, unicode.IsSpace); n > -1 {
+			directive, includePath = line[:n], strings.TrimSpace(line[n:])
+		}
+		switch directive {
+		case "!include":
+			result.kind = lineTypeInclude
+		case "!includedir":
+			result.kind = lineTypeIncludeDir
+		default:
+			return nil, fmt.Errorf("Unknown directive %s", directive)
+		}
+		if includePath == "" {
+			return nil, fmt.Errorf("Directive %s requires a path", directive)
+		}
+		result.includePath = includePath
+		return result, nil
+	}
+
 	if line[0] == '[' {
 		endIndex := strings.Index(line, "]")
 		hashIndex := strings.Index(line, "#")
@@ -497,6 +1312,14 @@ func parseLine(line string) (*parsedLine, error) {
 		}
 		result.kind = lineTypeSectionHeader
 		result.sectionName = line[1:endIndex]
+		// Only a colon surrounded by spaces denotes a parent section, so that
+		// headers such as "[host:3306]" remain literal section names
+		if name, parent, hasParent := strings.Cut(result.sectionName, " : "); hasParent {
+			result.sectionName, result.parentName = strings.TrimSpace(name), strings.TrimSpace(parent)
+			if result.sectionName == "" || result.parentName == "" {
+				return nil, errors.New("section name and parent section name must both be non-empty")
+			}
+		}
 		if hashIndex > -1 {
 			result.comment = line[hashIndex+1:]
 		}
@@ -548,6 +1371,8 @@ func parseLine(line string) (*parsedLine, error) {
 	}
 
 	var hasValue bool
+	rawKey, _, _ := strings.Cut(line, "=")
+	result.rawKey = strings.TrimSpace(rawKey)
 	result.key, result.value, hasValue, result.isLoose = NormalizeOptionToken(line)
 	if hasValue {
 		result.kind = lineTypeKeyValue
@@ -1,8 +1,14 @@
 package mybase
 
 import (
+	"errors"
 	"io/ioutil"
+	"maps"
 	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -259,6 +265,7 @@ func TestParseLine(t *testing.T) {
 			comment:     comment,
 			kind:        kind,
 			isLoose:     isLoose,
+			rawKey:      result.rawKey, // see TestFileOptionLocation
 		}
 		if *result != expect {
 			t.Errorf("Result %v does not match expectation %v", *result, expect)
@@ -285,9 +292,35 @@ func TestParseLine(t *testing.T) {
 	assertLine("foo='first' part of value only is quoted", "", "foo", "'first' part of value only is quoted", "", lineTypeKeyValue, false)
 	assertLine("foo='first' and last parts of value are 'quoted'", "", "foo", "'first' and last parts of value are 'quoted'", "", lineTypeKeyValue, false)
 
+	result, err := parseLine("[production-eu : production] # inherits")
+	if err != nil || result.kind != lineTypeSectionHeader || result.sectionName != "production-eu" || result.parentName != "production" {
+		t.Errorf("Unexpected result from parsing section header with parent: %+v, err=%v", result, err)
+	}
+	result, err = parseLine("[host:3306]")
+	if err != nil || result.sectionName != "host:3306" || result.parentName != "" {
+		t.Errorf("Unexpected result from parsing section header with unspaced colon: %+v, err=%v", result, err)
+	}
+	result, err = parseLine("  !includedir   conf.d  ")
+	if err != nil || result.kind != lineTypeIncludeDir || result.includePath != "conf.d" {
+		t.Errorf("Unexpected result from parsing includedir line: %+v, err=%v", result, err)
+	}
+	result, err = parseLine("!include\t/etc/extra.cnf")
+	if err != nil || result.kind != lineTypeInclude || result.includePath != "/etc/extra.cnf" {
+		t.Errorf("Unexpected result from parsing tab-separated include line: %+v, err=%v", result, err)
+	}
+	result, err = parseLine("!include /etc/extra.cnf   # note")
+	if err != nil || result.kind != lineTypeInclude || result.includePath != "/etc/extra.cnf" || result.comment != " note" {
+		t.Errorf("Unexpected result from parsing include line with comment: %+v, err=%v", result, err)
+	}
+
 	assertLineHasErr("[section")
+	assertLineHasErr("!include")
+	assertLineHasErr("!include # no path")
+	assertLineHasErr("!includefoo /etc/foo.cnf")
 	assertLineHasErr("[section   # hmmm")
 	assertLineHasErr("[section] lol # lolol")
+	assertLineHasErr("[section : ]")
+	assertLineHasErr("[ : parent]")
 	assertLineHasErr(`"key"="value"`)
 	assertLineHasErr("key\\=still-key = value")
 	assertLineHasErr(`no-terminator = "this quote does not end`)
@@ -334,3 +367,494 @@ func TestFileSectionValues(t *testing.T) {
 		t.Errorf("SectionValues unexpectedly did not return a copy of the map? Original map contents now %v", origMap)
 	}
 }
+
+func TestParseIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, contents string) {
+		t.Helper()
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			t.Fatalf("Unable to create dir for %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(contents), 0666); err != nil {
+			t.Fatalf("Unable to write %s: %v", path, err)
+		}
+	}
+	writeFile("main.cnf", "mystring=main\n[one]\n!include extra.cnf\nmybool\n[two]\n!includedir conf.d\n")
+	writeFile("extra.cnf", "otherstring=extra\n[three]\nmystring=three\n")
+	writeFile("conf.d/b.cnf", "otherstring=b\n")
+	writeFile("conf.d/a.cnf", "otherstring=a\nmystring=a\n")
+	writeFile("conf.d/ignored.txt", "this file is ignored\n")
+
+	cmd := NewCommand("test", "1.0", "this is for testing", nil)
+	cmd.AddOption(StringOption("mystring", 0, "", ""))
+	cmd.AddOption(StringOption("otherstring", 0, "", ""))
+	cmd.AddOption(BoolOption("mybool", 0, false, ""))
+	cfg := NewConfig(&CommandLine{Command: cmd})
+
+	f := NewFile(dir, "main.cnf")
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	expected := map[string]map[string]string{
+		"":      {"mystring": "main"},
+		"one":   {"otherstring": "extra", "mybool": "1"},
+		"two":   {"otherstring": "b", "mystring": "a"},
+		"three": {"mystring": "three"},
+	}
+	for sectionName, values := range expected {
+		if actual := f.SectionValues(sectionName); !reflect.DeepEqual(actual, values) {
+			t.Errorf("Unexpected values in section %q: expected %v, found %v", sectionName, values, actual)
+		}
+	}
+
+	// Rewriting the file should preserve the directives, without inlining any
+	// values from the included files
+	f.SetOptionValue("one", "mystring", "new")
+	if err := f.Write(true); err != nil {
+		t.Errorf("Unexpected error from Write: %v", err)
+	} else if contents, _ := os.ReadFile(f.Path()); string(contents) != "mystring=main\n[one]\n!include extra.cnf\nmybool\nmystring=new\n[two]\n!includedir conf.d\n" {
+		t.Errorf("Unexpected file contents after Write: %q", contents)
+	}
+
+	// An included file unsetting options takes precedence over earlier lines of
+	// the file itself, so setting them again cannot just rewrite those lines
+	writeFile("override.cnf", "mybool\nmystring=main\n!include unset.cnf\n")
+	writeFile("unset.cnf", "skip-mybool\nloose-skip-mystring\n")
+	f = NewFile(dir, "override.cnf")
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	} else if values := f.SectionValues(""); values["mybool"] != "" || values["mystring"] != "''" {
+		t.Errorf("Unexpected values after Parse: %v", values)
+	}
+	f.SetOptionValue("", "mybool", "1")
+	f.SetOptionValue("", "mystring", "new")
+	if err := f.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	f = NewFile(dir, "override.cnf")
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	} else if values := f.SectionValues(""); values["mybool"] != "1" || values["mystring"] != "new" {
+		t.Errorf("Unexpected values after Write: %v", values)
+	}
+
+	// Errors in included files should report the included file's path
+	writeFile("conf.d/c.cnf", "mystring=c\ninvalid=fail\n")
+	f = NewFile(dir, "main.cnf")
+	var ondErr OptionNotDefinedError
+	if err := f.Parse(cfg); !errors.As(err, &ondErr) || ondErr.Source != filepath.Join(dir, "conf.d", "c.cnf")+" line 2" {
+		t.Errorf("Unexpected error from Parse: %v", err)
+	}
+	writeFile("conf.d/c.cnf", "mystring=c\n[broken\n")
+	f = NewFile(dir, "main.cnf")
+	var fpfErr FileParseFormatError
+	if err := f.Parse(cfg); !errors.As(err, &fpfErr) || fpfErr.FilePath != filepath.Join(dir, "conf.d", "c.cnf") || fpfErr.LineNumber != 2 {
+		t.Errorf("Unexpected error from Parse: %v", err)
+	}
+
+	// Include cycles and nonexistent files should be detected
+	writeFile("conf.d/c.cnf", "!include ../main.cnf\n")
+	f = NewFile(dir, "main.cnf")
+	if err := f.Parse(cfg); !errors.As(err, &fpfErr) || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("Unexpected error from Parse: %v", err)
+	}
+	writeFile("conf.d/c.cnf", "!include doesnt-exist.cnf\n")
+	f = NewFile(dir, "main.cnf")
+	if err := f.Parse(cfg); !errors.As(err, &fpfErr) || fpfErr.FilePath != filepath.Join(dir, "conf.d", "c.cnf") || fpfErr.LineNumber != 1 {
+		t.Errorf("Unexpected error from Parse: %v", err)
+	}
+}
+
+func TestFileOptionLocation(t *testing.T) {
+	dir := t.TempDir()
+	mainContents := "hasshort=main\n\n[prod]\nloose_visible = 5  # comment\n!include extra.cnf\n"
+	extraContents := "[other]\nskip-bool1\n"
+	if err := os.WriteFile(filepath.Join(dir, "main.cnf"), []byte(mainContents), 0666); err != nil {
+		t.Fatalf("Unable to write file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "extra.cnf"), []byte(extraContents), 0666); err != nil {
+		t.Fatalf("Unable to write file: %v", err)
+	}
+	cmd := simpleCommand()
+	cfg := ParseFakeCLI(t, cmd, "mycommand arg1")
+	f := NewFile(dir, "main.cnf")
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	f.UseSection("other", "prod")
+
+	cases := map[string]OptionLocation{
+		"hasshort": {FilePath: f.Path(), Section: "", LineNumber: 1, RawKey: "hasshort"},
+		"visible":  {FilePath: f.Path(), Section: "prod", LineNumber: 4, RawKey: "loose_visible"},
+		"bool1":    {FilePath: filepath.Join(dir, "extra.cnf"), Section: "other", LineNumber: 2, RawKey: "skip-bool1"},
+	}
+	for name, expected := range cases {
+		if actual, ok := f.OptionLocation(name); !ok || actual != expected {
+			t.Errorf("Unexpected result from OptionLocation(%q): %+v, %t", name, actual, ok)
+		}
+	}
+	if loc, ok := f.OptionLocation("hidden"); ok {
+		t.Errorf("Expected OptionLocation to return false for unset option, instead found %+v", loc)
+	}
+	f.SetOptionValue("prod", "hidden", "new")
+	if loc, ok := f.OptionLocation("hidden"); ok {
+		t.Errorf("Expected OptionLocation to return false for option set by SetOptionValue, instead found %+v", loc)
+	}
+
+	// Getter errors should indicate where the value was set
+	cmd.AddOption(StringOption("size", 0, "", "dummy description"))
+	cfg.AddSource(f)
+	f.SetOptionValue("", "size", "lots")
+	if _, err := cfg.GetInt("hasshort"); err == nil || !strings.Contains(err.Error(), f.Path()+" line 1") {
+		t.Errorf("Unexpected error from GetInt: %v", err)
+	}
+	if _, err := cfg.GetEnum("visible", "a", "b"); err == nil || !strings.HasPrefix(err.Error(), "Option visible in "+f.Path()+" line 4: expected one of") {
+		t.Errorf("Unexpected error from GetEnum: %v", err)
+	}
+	if _, err := cfg.GetBytes("hasshort"); err == nil || !strings.Contains(err.Error(), f.Path()+" line 1") {
+		t.Errorf("Unexpected error from GetBytes: %v", err)
+	}
+	if _, err := cfg.GetInt("size"); err == nil || strings.Contains(err.Error(), " line ") {
+		t.Errorf("Unexpected error from GetInt: %v", err)
+	}
+}
+
+func TestFileDottedKeys(t *testing.T) {
+	cmd := simpleCommand()
+	cmd.AddOption(MapOption("labels", 0, "", "dummy description"))
+	cfg := ParseFakeCLI(t, cmd, "mycommand arg1")
+	contents := "labels.env=prod\nlabels.Team = \"db,infra\"\nlabels.note=a=b\nvisible.foo=bar\n[one]\nlabels=x=1\nlabels.y=2\n[two]\nlabels.z=3\nlabels=w=4\n"
+	if _, err := getParsedFile(cfg, false, contents); err == nil {
+		t.Error("Expected dotted key for non-map option to be an error, but it was not")
+	}
+	contents = strings.Replace(contents, "visible.foo", "loose-visible.foo", 1)
+	f, err := getParsedFile(cfg, false, contents)
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+	cfg.AddSource(f)
+	expected := map[string]map[string]string{
+		"":    {"env": "prod", "Team": "db,infra", "note": "a=b"},
+		"one": {"x": "1", "y": "2"},
+		"two": {"w": "4"},
+	}
+	for section, expectedMap := range expected {
+		f.UseSection(section)
+		cfg.MarkDirty()
+		if actual, err := cfg.GetMap("labels", ',', '='); err != nil || !maps.Equal(actual, expectedMap) {
+			t.Errorf("Unexpected value from GetMap in section %q: expected %v, found %v, %v", section, expectedMap, actual, err)
+		}
+	}
+	f.UseSection("one")
+	if loc, ok := f.OptionLocation("labels"); !ok || loc.RawKey != "labels.y" || loc.LineNumber != 7 {
+		t.Errorf("Unexpected OptionLocation for folded option: %+v, %t", loc, ok)
+	}
+
+	// Writing a changed map value should replace the dotted-key lines with a
+	// single line; removing the value should remove all of them
+	dir := t.TempDir()
+	f.Dir = dir
+	f.SetOptionValue("", "labels", "env=dev")
+	f.UnsetOptionValue("one", "labels")
+	if err := f.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	f2 := NewFile(dir, f.Name)
+	if err := f2.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	if !f.SameContents(f2) {
+		t.Errorf("Expected re-parsed file to have same contents, but it did not: %v vs %v", f.SectionValues(""), f2.SectionValues(""))
+	}
+	if values := f2.SectionValues("one"); len(values) != 0 {
+		t.Errorf("Expected section one to have no values after UnsetOptionValue and Write, instead found %v", values)
+	}
+	if strings.Contains(f2.contents, "labels.env") || strings.Contains(f2.contents, "labels.y") || !strings.HasPrefix(f2.contents, "labels=env=dev\n") {
+		t.Errorf("Unexpected contents after Write:\n%s", f2.contents)
+	}
+
+	// Setting a map value in a section which combines a full value with dotted
+	// keys should also round-trip
+	f2.SetOptionValue("two", "labels", "v=5")
+	f2.Dir = t.TempDir()
+	if err := f2.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	f3 := NewFile(f2.Dir, f2.Name)
+	if err := f3.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	f3.UseSection("two")
+	cfg = ParseFakeCLI(t, cmd, "mycommand arg1", f3)
+	if actual, err := cfg.GetMap("labels", ',', '='); err != nil || !maps.Equal(actual, map[string]string{"v": "5"}) {
+		t.Errorf("Unexpected value from GetMap after round-trip: %v, %v\n%s", actual, err, f3.contents)
+	}
+}
+
+func TestFileSectionInheritance(t *testing.T) {
+	cmd := simpleCommand()
+	cfg := ParseFakeCLI(t, cmd, "mycommand arg1")
+	contents := "visible=default\n[production-eu : production]\nhasshort=eu\n[production]\nvisible=prod\nhidden=prod\n[production-eu-west : production-eu]\nhidden=west\n[staging]\nhidden=staging\n"
+	f, err := getParsedFile(cfg, false, contents)
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+	if parent := f.sectionIndex["production-eu"].Parent; parent != "production" {
+		t.Errorf("Unexpected Parent: %q", parent)
+	}
+	if err := f.UseSection("production-eu-west", "staging"); err != nil {
+		t.Fatalf("Unexpected error from UseSection: %v", err)
+	}
+	expected := []string{"production-eu-west", "production-eu", "production", "staging", ""}
+	if !slices.Equal(f.selected, expected) {
+		t.Errorf("Unexpected selected sections: expected %q, found %q", expected, f.selected)
+	}
+	cfg.AddSource(f)
+	if cfg.Get("hidden") != "west" || cfg.Get("hasshort") != "eu" || cfg.Get("visible") != "prod" {
+		t.Errorf("Unexpected values from inherited sections: hidden=%q hasshort=%q visible=%q", cfg.Get("hidden"), cfg.Get("hasshort"), cfg.Get("visible"))
+	}
+
+	values := f.EffectiveSectionValues("production-eu-west")
+	if !maps.Equal(values, map[string]string{"hidden": "west", "hasshort": "eu", "visible": "prod"}) {
+		t.Errorf("Unexpected return from EffectiveSectionValues: %v", values)
+	}
+	if values := f.SectionValues("production-eu-west"); len(values) != 1 {
+		t.Errorf("Unexpected return from SectionValues: %v", values)
+	}
+	if values := f.EffectiveSectionValues("doesnt-exist"); len(values) != 0 {
+		t.Errorf("Unexpected return from EffectiveSectionValues for nonexistent section: %v", values)
+	}
+	sections := f.EffectiveSectionsWithOption("visible")
+	if !slices.Equal(sections, []string{"", "production-eu", "production", "production-eu-west"}) {
+		t.Errorf("Unexpected return from EffectiveSectionsWithOption: %q", sections)
+	}
+	if sections := f.SectionsWithOption("visible"); len(sections) != 2 {
+		t.Errorf("Unexpected return from SectionsWithOption: %q", sections)
+	}
+
+	// Writing the file should preserve the header syntax
+	f.Dir = t.TempDir()
+	f.SetOptionValue("production-eu", "visible", "eu")
+	if err := f.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	f2 := NewFile(f.Dir, f.Name)
+	if err := f2.Parse(cfg); err != nil || !f.SameContents(f2) {
+		t.Errorf("Unexpected result from re-parsing written file: %v\n%s", err, f.contents)
+	}
+
+	cases := map[string]string{
+		"[a : b]\n":                              "line 1: Section a has unknown parent section b",
+		"[a : b]\n[b : c]\n[c : a]\n":            "line 1: Section inheritance cycle: a -> b -> c -> a",
+		"[a]\n[b : b]\n":                         "line 2: Section inheritance cycle: b -> b",
+		"[a : b]\n[b]\n[a : c]\n":                "line 3: Section a was previously declared with parent section b",
+		"[a : b]\n[b]\n[a : b]\n[a]\nhidden=x\n": "",
+		"[host:3306]\nhidden=x\n":                "",
+	}
+	for contents, expected := range cases {
+		_, err := getParsedFile(cfg, false, contents)
+		if expected == "" {
+			if err != nil {
+				t.Errorf("Unexpected error parsing %q: %v", contents, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error parsing %q to contain %q, instead found %v", contents, expected, err)
+		}
+	}
+}
+
+func TestFileSectionPatterns(t *testing.T) {
+	cmd := simpleCommand()
+	cfg := ParseFakeCLI(t, cmd, "mycommand arg1")
+	contents := "visible=default\n[*]\nhasshort=any\n[prod-*]\nhidden=prod\nvisible=prod\n[prod-eu-*]\nhidden=eu\n[prod-eu-west]\nhidden=west\n[prod-?u-*]\nhasshort=eu\n[prod-[a]\nhidden=literal\n[staging : prod-*]\n"
+	f, err := getParsedFile(cfg, false, contents)
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+
+	cases := map[string][]string{
+		"prod-eu-west": {"prod-eu-west", "prod-eu-*", "prod-?u-*", "prod-*", "*", ""},
+		"prod-eu-east": {"prod-eu-*", "prod-?u-*", "prod-*", "*", ""},
+		"prod-us":      {"prod-*", "*", ""},
+		"prod-*":       {"prod-*", "*", ""},
+		"prod-[a":      {"prod-[a", "prod-*", "*", ""},
+		"staging":      {"staging", "prod-*", "*", ""},
+		"":             {""},
+	}
+	for name, expected := range cases {
+		if !f.HasSection(name) {
+			t.Errorf("Expected HasSection(%q) to return true, but it did not", name)
+		}
+		if err := f.UseSection(name); err != nil {
+			t.Errorf("Unexpected error from UseSection(%q): %v", name, err)
+		} else if !slices.Equal(f.selected, expected) {
+			t.Errorf("Unexpected selected sections for %q: expected %q, found %q", name, expected, f.selected)
+		}
+	}
+
+	// Exact matches for all names should take precedence over patterns
+	if err := f.UseSection("prod-us", "prod-eu-west"); err != nil {
+		t.Fatalf("Unexpected error from UseSection: %v", err)
+	}
+	expected := []string{"prod-*", "*", "prod-eu-west", "prod-eu-*", "prod-?u-*", ""}
+	if !slices.Equal(f.selected, expected) {
+		t.Errorf("Unexpected selected sections: expected %q, found %q", expected, f.selected)
+	}
+	f.UseSection("prod-eu-east")
+	cfg.AddSource(f)
+	if cfg.Get("hidden") != "eu" || cfg.Get("hasshort") != "eu" || cfg.Get("visible") != "prod" {
+		t.Errorf("Unexpected values from pattern sections: hidden=%q hasshort=%q visible=%q", cfg.Get("hidden"), cfg.Get("hasshort"), cfg.Get("visible"))
+	}
+
+	// Without a catch-all pattern, unmatched names should still be reported
+	f, err = getParsedFile(cfg, false, "[prod-*]\nhidden=prod\n")
+	if err != nil {
+		t.Fatalf("Unexpected error getting fake parsed file: %v", err)
+	}
+	if f.HasSection("staging") || !f.HasSection("prod-1") {
+		t.Error("Unexpected return from HasSection")
+	}
+	if err := f.UseSection("prod-1", "staging"); err == nil || !strings.HasSuffix(err.Error(), "missing section: staging") {
+		t.Errorf("Unexpected error from UseSection: %v", err)
+	}
+
+	specificities := map[string]int{"*": 0, "a*": 1, "a?c": 2, "a[bc]d*": 2, `a\*`: 2, `a[\]]b`: 2}
+	for pattern, expected := range specificities {
+		if actual := patternSpecificity(pattern); actual != expected {
+			t.Errorf("Unexpected specificity for %q: expected %d, found %d", pattern, expected, actual)
+		}
+	}
+}
+
+func TestFileWritePreservesFormatting(t *testing.T) {
+	cmd := NewCommand("test", "1.0", "this is for testing", nil)
+	cmd.AddOption(StringOption("mystring", 0, "", ""))
+	cmd.AddOption(StringOption("otherstring", 0, "", "").ValueOptional())
+	cmd.AddOption(BoolOption("mybool", 0, false, ""))
+	cfg := NewConfig(&CommandLine{Command: cmd})
+
+	contents := `# Header comment
+
+Loose_MyString = "quoted value"   # trailing comment
+skip-mybool
+doesnt-exist=ignored since loose below
+
+# Comment about section one
+[one]
+mystring=one
+  otherstring   # indented, with comment
+mystring=one-again # last one wins
+
+[two] # section comment
+mybool
+`
+	f := NewFile(t.TempDir(), "formatting.cnf")
+	if err := os.WriteFile(f.Path(), []byte(contents), 0666); err != nil {
+		t.Fatalf("Unable to write %s: %v", f.Path(), err)
+	}
+	f.IgnoreOptions("doesnt-exist")
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	assertWrite := func(expected string) {
+		t.Helper()
+		if err := f.Write(true); err != nil {
+			t.Fatalf("Unexpected error from Write: %v", err)
+		}
+		actual, err := os.ReadFile(f.Path())
+		if err != nil {
+			t.Fatalf("Unexpected error reading %s: %v", f.Path(), err)
+		}
+		if string(actual) != expected {
+			t.Errorf("Unexpected file contents after Write.\nExpected:\n%s\nActual:\n%s", expected, actual)
+		}
+	}
+
+	// Writing without changes should not modify anything
+	assertWrite(contents)
+
+	// Changing values should only modify those lines, preserving key spelling,
+	// spacing and comments; removing values should remove all lines for that
+	// option in the section; adding values should append to the section
+	f.SetOptionValue("", "mystring", "'new value'")
+	f.SetOptionValue("", "mybool", "1")
+	f.UnsetOptionValue("one", "mystring")
+	f.SetOptionValue("two", "mybool", "")
+	f.SetOptionValue("two", "mystring", "added")
+	f.SetOptionValue("three", "otherstring", "")
+	expected := `# Header comment
+
+Loose_MyString = 'new value'   # trailing comment
+mybool
+doesnt-exist=ignored since loose below
+
+# Comment about section one
+[one]
+  otherstring   # indented, with comment
+
+[two] # section comment
+skip-mybool
+mystring=added
+
+[three]
+otherstring
+`
+	assertWrite(expected)
+
+	// Re-parsing the written file should yield the same contents, and writing
+	// again should be a no-op
+	f2 := NewFile(f.Dir, f.Name)
+	f2.IgnoreOptions("doesnt-exist")
+	if err := f2.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	if !f.SameContents(f2) {
+		t.Error("Expected re-parsed file to have same contents, but it did not")
+	}
+	assertWrite(expected)
+
+	// New values in the default section should be placed after its last
+	// existing line
+	f.UnsetOptionValue("", "mystring")
+	f.UnsetOptionValue("", "mybool")
+	expected = strings.Replace(expected, "Loose_MyString = 'new value'   # trailing comment\nmybool\n", "", 1)
+	assertWrite(expected)
+	f.SetOptionValue("", "mystring", "top")
+	assertWrite(strings.Replace(expected, "loose below\n", "loose below\nmystring=top\n", 1))
+}
+
+func TestFileWriteDefaultSectionPlacement(t *testing.T) {
+	cmd := NewCommand("test", "1.0", "this is for testing", nil)
+	cmd.AddOption(StringOption("mystring", 0, "", ""))
+	cfg := NewConfig(&CommandLine{Command: cmd})
+
+	f := NewFile(t.TempDir(), "placement.cnf")
+	f.SetOptionValue("", "mystring", "hello")
+	f.SetOptionValue("beta", "mystring", "world")
+	if err := f.Write(false); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	if actual, _ := os.ReadFile(f.Path()); string(actual) != "mystring=hello\n\n[beta]\nmystring=world\n" {
+		t.Errorf("Unexpected contents from writing new file: %q", actual)
+	}
+
+	// New values in a default section which lacks any lines should be placed
+	// prior to the first section header, skipping past any comments attached to
+	// that header
+	contents := "# File header comment\n\n# Beta settings\n[beta]\nmystring=world\n"
+	if err := os.WriteFile(f.Path(), []byte(contents), 0666); err != nil {
+		t.Fatalf("Unable to write %s: %v", f.Path(), err)
+	}
+	f = NewFile(f.Dir, f.Name)
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	f.SetOptionValue("", "mystring", "hello")
+	if err := f.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	if actual, _ := os.ReadFile(f.Path()); string(actual) != "# File header comment\nmystring=hello\n\n# Beta settings\n[beta]\nmystring=world\n" {
+		t.Errorf("Unexpected contents from rewriting file: %q", actual)
+	}
+}
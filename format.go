@@ -0,0 +1,611 @@
+package mybase
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FileFormat represents a syntax for option files, other than the default
+// MySQL-style ini syntax. A File uses a FileFormat if its Format field is set,
+// or if its name has an extension handled by one of this package's built-in
+// formats: ".json" for JSONFormat, or ".toml" for TOMLFormat.
+//
+// A FileFormat is only responsible for syntax. Option names returned by Parse
+// are validated by File.Parse using Config.FindOption, with the same semantics
+// as ini files: unknown options are an OptionNotDefinedError unless prefixed
+// with "loose-" or ignored via File.IgnoreUnknownOptions or
+// Config.LooseFileOptions, and prefixes such as "skip-" negate boolean
+// options. Include directives are not supported by FileFormats.
+type FileFormat interface {
+	// Parse returns all option settings in contents, in the order they appear.
+	// Errors relating to invalid syntax should be a FileParseFormatError, in
+	// which case File.Parse fills in the FilePath field.
+	Parse(contents string) ([]FileEntry, error)
+
+	// Format returns file contents representing all values in the supplied
+	// sections, as used by File.Write. The first section is always the default
+	// nameless section "". Comments and formatting from the original file need
+	// not be preserved.
+	Format(sections []*Section) (string, error)
+}
+
+// FileEntry represents a single option setting, as returned by
+// FileFormat.Parse.
+type FileEntry struct {
+	Section    string // Name of the section containing the setting, or "" for the default section
+	Key        string // Option name as written, possibly including a prefix such as "loose-" or "skip-"
+	Value      string // Option value; ignored if HasValue is false
+	HasValue   bool   // false if the option was supplied without a value, which is only permitted for some options
+	LineNumber int    // Line number of the setting, starting from 1, or 0 if unknown
+}
+
+// formatForExtension returns the built-in FileFormat corresponding to the
+// supplied file name's extension, or nil if the file should use the default
+// ini syntax.
+func formatForExtension(name string) FileFormat {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		return JSONFormat{}
+	case ".toml":
+		return TOMLFormat{}
+	default:
+		return nil
+	}
+}
+
+// sectionEntries returns the option names set in section, sorted by name,
+// along with all values for each name. Options marked as Repeatable may have
+// more than one value.
+func sectionEntries(section *Section) (names []string, values map[string][]string) {
+	names = make([]string, 0, len(section.Values))
+	values = make(map[string][]string, len(section.Values))
+	for name, value := range section.Values {
+		names = append(names, name)
+		if repeated, ok := section.repeated[name]; ok {
+			values[name] = repeated
+		} else {
+			values[name] = []string{value}
+		}
+	}
+	slices.Sort(names)
+	return names, values
+}
+
+// isBoolOption returns true if the named option in section is known to be
+// a boolean option.
+func isBoolOption(section *Section, name string) bool {
+	opt := section.opts[name]
+	return opt != nil && opt.Type == OptionTypeBool
+}
+
+// JSONFormat is a FileFormat for option files containing a JSON object. Keys
+// with scalar values are options in the default section, whereas keys with
+// object values are sections. Objects nested within sections are treated as
+// sections whose names are joined by dots, for example "a.b". JSON strings,
+// numbers, and booleans may all be used as option values, and null represents
+// an option supplied without a value. An array represents an option supplied
+// once for each of its elements, typically for use with Option.Repeatable.
+type JSONFormat struct{}
+
+// Parse satisfies the FileFormat interface.
+func (JSONFormat) Parse(contents string) ([]FileEntry, error) {
+	p := &jsonParser{
+		contents: contents,
+		dec:      json.NewDecoder(strings.NewReader(contents)),
+	}
+	p.dec.UseNumber()
+	if tok, err := p.dec.Token(); err != nil {
+		return nil, p.error(err)
+	} else if tok != json.Delim('{') {
+		return nil, p.error(errors.New("Top-level value must be an object"))
+	}
+	if err := p.object(""); err != nil {
+		return nil, err
+	}
+	if _, err := p.dec.Token(); err != io.EOF {
+		return nil, p.error(errors.New("Unexpected data after top-level object"))
+	}
+	return p.entries, nil
+}
+
+// jsonParser tracks the state of JSONFormat.Parse.
+type jsonParser struct {
+	contents string
+	dec      *json.Decoder
+	entries  []FileEntry
+}
+
+// object parses the members of an object whose opening brace has already been
+// consumed, through its closing brace.
+func (p *jsonParser) object(section string) error {
+	for p.dec.More() {
+		lineNumber := p.lineNumber()
+		tok, err := p.dec.Token()
+		if err != nil {
+			return p.error(err)
+		}
+		key := tok.(string) // object keys are always strings
+		if tok, err = p.dec.Token(); err != nil {
+			return p.error(err)
+		}
+		switch tok {
+		case json.Delim('{'):
+			name := key
+			if section != "" {
+				name = section + "." + key
+			}
+			if err := p.object(name); err != nil {
+				return err
+			}
+		case json.Delim('['):
+			for p.dec.More() {
+				if tok, err = p.dec.Token(); err != nil {
+					return p.error(err)
+				} else if err := p.add(section, key, tok, lineNumber); err != nil {
+					return err
+				}
+			}
+			if _, err := p.dec.Token(); err != nil {
+				return p.error(err)
+			}
+		default:
+			if err := p.add(section, key, tok, lineNumber); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := p.dec.Token() // closing brace
+	if err != nil {
+		return p.error(err)
+	}
+	return nil
+}
+
+// add appends an entry for the scalar value tok.
+func (p *jsonParser) add(section, key string, tok json.Token, lineNumber int) error {
+	entry := FileEntry{
+		Section:    section,
+		Key:        key,
+		HasValue:   true,
+		LineNumber: lineNumber,
+	}
+	switch tok := tok.(type) {
+	case string:
+		entry.Value = tok
+	case json.Number:
+		entry.Value = tok.String()
+	case bool:
+		entry.Value = strconv.FormatBool(tok)
+	case nil:
+		entry.HasValue = false
+	default:
+		return p.error(fmt.Errorf("Value for %s must be a string, number, boolean, or null", key))
+	}
+	p.entries = append(p.entries, entry)
+	return nil
+}
+
+// lineNumber returns the line number of the next token to be read.
+func (p *jsonParser) lineNumber() int {
+	offset := int(p.dec.InputOffset())
+	for offset < len(p.contents) && strings.IndexByte(" \t\r\n,:", p.contents[offset]) >= 0 {
+		offset++
+	}
+	return strings.Count(p.contents[:offset], "\n") + 1
+}
+
+// error converts err to a FileParseFormatError.
+func (p *jsonParser) error(err error) error {
+	lineNumber := p.lineNumber()
+	var syntaxErr *json.SyntaxError
+	if errors.As(err, &syntaxErr) {
+		lineNumber = strings.Count(p.contents[:min(int(syntaxErr.Offset), len(p.contents))], "\n") + 1
+	} else if err == io.EOF {
+		err = errors.New("Unexpected end of file")
+	}
+	return FileParseFormatError{
+		Problem:    err.Error(),
+		LineNumber: lineNumber,
+	}
+}
+
+// Format satisfies the FileFormat interface. Options are written in order by
+// name, with each section as a nested object.
+func (JSONFormat) Format(sections []*Section) (string, error) {
+	var b strings.Builder
+	b.WriteString("{")
+	var wroteMember bool
+	writeMember := func(indent, key string) {
+		if wroteMember {
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&b, "\n%s%s: ", indent, jsonString(key))
+		wroteMember = true
+	}
+	writeSection := func(section *Section, indent string) {
+		names, values := sectionEntries(section)
+		for _, name := range names {
+			writeMember(indent, name)
+			var encoded []string
+			for _, value := range values[name] {
+				if isBoolOption(section, name) {
+					encoded = append(encoded, strconv.FormatBool(BoolValue(value)))
+				} else {
+					encoded = append(encoded, jsonString(value))
+				}
+			}
+			if len(encoded) == 1 {
+				b.WriteString(encoded[0])
+			} else {
+				b.WriteString("[" + strings.Join(encoded, ", ") + "]")
+			}
+		}
+	}
+	for _, section := range sections {
+		if section.Name == "" {
+			writeSection(section, "  ")
+		} else if len(section.Values) > 0 {
+			writeMember("  ", section.Name)
+			b.WriteString("{")
+			wroteMember = false
+			writeSection(section, "    ")
+			b.WriteString("\n  }")
+			wroteMember = true
+		}
+	}
+	if wroteMember {
+		b.WriteString("\n")
+	}
+	b.WriteString("}\n")
+	return b.String(), nil
+}
+
+// jsonString returns s encoded as a JSON string, without escaping HTML
+// characters.
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) // cannot fail for a string
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+// TOMLFormat is a FileFormat for option files using a subset of TOML syntax.
+// Tables, such as "[name]" or "[a.b]", are sections. Supported values are
+// basic and literal strings, integers, floats, booleans, and single-line
+// arrays of these types; an array represents an option supplied once for each
+// of its elements, typically for use with Option.Repeatable. Integers, floats,
+// and other unquoted values such as dates are used as-is. Dotted keys are
+// not interpreted as tables, but rather are passed through as-is, permitting
+// the dotted-key syntax of MapOption. Multi-line strings, inline tables, and
+// arrays of tables are not supported.
+type TOMLFormat struct{}
+
+// Parse satisfies the FileFormat interface.
+func (TOMLFormat) Parse(contents string) ([]FileEntry, error) {
+	var entries []FileEntry
+	var section string
+	for n, line := range strings.Split(strings.ReplaceAll(contents, "\r\n", "\n"), "\n") {
+		s := &tomlScanner{input: line}
+		s.skipSpace()
+		var err error
+		switch {
+		case s.done() || s.peek() == '#':
+			continue
+		case strings.HasPrefix(s.rest(), "[["):
+			err = errors.New("Arrays of tables are not supported")
+		case s.peek() == '[':
+			s.pos++
+			if section, err = s.key(); err == nil {
+				err = s.expect(']')
+			}
+		default:
+			var key string
+			if key, err = s.key(); err == nil {
+				if err = s.expect('='); err == nil {
+					var values []string
+					if values, err = s.value(); err == nil {
+						for _, value := range values {
+							entries = append(entries, FileEntry{
+								Section:    section,
+								Key:        key,
+								Value:      value,
+								HasValue:   true,
+								LineNumber: n + 1,
+							})
+						}
+					}
+				}
+			}
+		}
+		if err == nil {
+			err = s.end()
+		}
+		if err != nil {
+			return nil, FileParseFormatError{
+				Problem:    err.Error(),
+				LineNumber: n + 1,
+			}
+		}
+	}
+	return entries, nil
+}
+
+// tomlScanner parses components of a single line of a TOML file.
+type tomlScanner struct {
+	input string
+	pos   int
+}
+
+func (s *tomlScanner) done() bool {
+	return s.pos >= len(s.input)
+}
+
+func (s *tomlScanner) peek() byte {
+	if s.done() {
+		return 0
+	}
+	return s.input[s.pos]
+}
+
+func (s *tomlScanner) rest() string {
+	return s.input[s.pos:]
+}
+
+func (s *tomlScanner) skipSpace() {
+	for !s.done() && (s.peek() == ' ' || s.peek() == '\t') {
+		s.pos++
+	}
+}
+
+// expect consumes the supplied character, after any whitespace.
+func (s *tomlScanner) expect(c byte) error {
+	s.skipSpace()
+	if s.peek() != c {
+		return fmt.Errorf("Expected %q", c)
+	}
+	s.pos++
+	return nil
+}
+
+// end returns an error if anything other than whitespace or a comment remains.
+func (s *tomlScanner) end() error {
+	s.skipSpace()
+	if !s.done() && s.peek() != '#' {
+		return fmt.Errorf("Unexpected text %q", s.rest())
+	}
+	return nil
+}
+
+// key consumes a key, which may consist of dotted bare or quoted parts. The
+// parts are returned joined by dots.
+func (s *tomlScanner) key() (string, error) {
+	var parts []string
+	for {
+		s.skipSpace()
+		var part string
+		var err error
+		switch c := s.peek(); {
+		case c == '"' || c == '\'':
+			part, err = s.quoted()
+		default:
+			start := s.pos
+			for !s.done() && isTOMLBareKeyChar(s.peek()) {
+				s.pos++
+			}
+			part = s.input[start:s.pos]
+			if part == "" {
+				err = errors.New("Missing key")
+			}
+		}
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, part)
+		s.skipSpace()
+		if s.peek() != '.' {
+			return strings.Join(parts, "."), nil
+		}
+		s.pos++
+	}
+}
+
+// value consumes a value, returning a slice with one element for scalar
+// values, or one element per array element for arrays.
+func (s *tomlScanner) value() ([]string, error) {
+	s.skipSpace()
+	switch s.peek() {
+	case '[':
+		s.pos++
+		var values []string
+		for {
+			s.skipSpace()
+			if s.peek() == ']' {
+				s.pos++
+				return values, nil
+			}
+			value, err := s.scalar()
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+			s.skipSpace()
+			if s.peek() == ',' {
+				s.pos++
+			} else if s.peek() != ']' {
+				return nil, errors.New("Expected ',' or ']' in array")
+			}
+		}
+	case '{':
+		return nil, errors.New("Inline tables are not supported")
+	}
+	value, err := s.scalar()
+	if err != nil {
+		return nil, err
+	}
+	return []string{value}, nil
+}
+
+// scalar consumes a string or unquoted value such as a number or boolean.
+func (s *tomlScanner) scalar() (string, error) {
+	s.skipSpace()
+	if c := s.peek(); c == '"' || c == '\'' {
+		if strings.HasPrefix(s.rest(), `"""`) || strings.HasPrefix(s.rest(), "'''") {
+			return "", errors.New("Multi-line strings are not supported")
+		}
+		return s.quoted()
+	} else if c == '[' || c == '{' {
+		return "", errors.New("Nested arrays and inline tables are not supported")
+	}
+	start := s.pos
+	for !s.done() && !strings.ContainsRune(" \t,]#", rune(s.peek())) {
+		s.pos++
+	}
+	if s.pos == start {
+		return "", errors.New("Missing value")
+	}
+	return s.input[start:s.pos], nil
+}
+
+// quoted consumes a basic string (double quotes, with escape sequences) or
+// literal string (single quotes, without escape sequences), returning its
+// contents.
+func (s *tomlScanner) quoted() (string, error) {
+	quote := s.peek()
+	s.pos++
+	var b strings.Builder
+	for !s.done() {
+		c := s.peek()
+		s.pos++
+		if c == quote {
+			return b.String(), nil
+		} else if c != '\\' || quote == '\'' {
+			b.WriteByte(c)
+			continue
+		}
+		escaped := s.peek()
+		s.pos++
+		switch escaped {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(escaped)
+		case 'u', 'U':
+			size := 4
+			if escaped == 'U' {
+				size = 8
+			}
+			if s.pos+size > len(s.input) {
+				return "", errors.New("Invalid unicode escape sequence")
+			}
+			code, err := strconv.ParseUint(s.input[s.pos:s.pos+size], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", errors.New("Invalid unicode escape sequence")
+			}
+			b.WriteRune(rune(code))
+			s.pos += size
+		default:
+			return "", fmt.Errorf("Invalid escape sequence \\%c", escaped)
+		}
+	}
+	return "", errors.New("String has no terminating quote")
+}
+
+func isTOMLBareKeyChar(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9') || c == '_' || c == '-'
+}
+
+// Format satisfies the FileFormat interface. Options are written in order by
+// name, with each non-default section as a table.
+func (TOMLFormat) Format(sections []*Section) (string, error) {
+	var b strings.Builder
+	for _, section := range sections {
+		if section.Name != "" {
+			if len(section.Values) == 0 {
+				continue
+			}
+			if b.Len() > 0 {
+				b.WriteString("\n")
+			}
+			fmt.Fprintf(&b, "[%s]\n", tomlKey(section.Name, true))
+		}
+		names, values := sectionEntries(section)
+		for _, name := range names {
+			var encoded []string
+			for _, value := range values[name] {
+				if isBoolOption(section, name) {
+					encoded = append(encoded, strconv.FormatBool(BoolValue(value)))
+				} else {
+					encoded = append(encoded, tomlString(value))
+				}
+			}
+			value := encoded[0]
+			if len(encoded) > 1 {
+				value = "[" + strings.Join(encoded, ", ") + "]"
+			}
+			fmt.Fprintf(&b, "%s = %s\n", tomlKey(name, false), value)
+		}
+	}
+	return b.String(), nil
+}
+
+// tomlKey returns key as a bare key if possible, or otherwise as a basic
+// string. If allowDots is true, dots are permitted in bare keys.
+func tomlKey(key string, allowDots bool) string {
+	for n := 0; n < len(key); n++ {
+		if !isTOMLBareKeyChar(key[n]) && (key[n] != '.' || !allowDots) {
+			return tomlString(key)
+		}
+	}
+	if key == "" {
+		return tomlString(key)
+	}
+	return key
+}
+
+// tomlString returns s encoded as a TOML basic string.
+func tomlString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
@@ -0,0 +1,244 @@
+package mybase
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func formatTestCommand() *Command {
+	cmd := simpleCommand()
+	cmd.AddOption(IntOption("port", 0, 3306, "dummy description"))
+	cmd.AddOption(StringOption("ignore-table", 0, "", "dummy description").Repeatable())
+	cmd.AddOption(MapOption("labels", 0, "", "dummy description"))
+	return cmd
+}
+
+func TestJSONFormat(t *testing.T) {
+	cfg := ParseFakeCLI(t, formatTestCommand(), "mycommand arg1")
+	contents := `{
+  "visible": "top level",
+  "port": 3307,
+  "bool1": null,
+  "skip-truthybool": true,
+  "loose-doesnt-exist": "ignored",
+  "ignore-table": ["a", "b"],
+  "labels.env": "prod",
+  "prod": {
+    "hasshort": "say \"hi\"",
+    "port": 3308,
+    "bool2": false,
+    "west": {
+      "visible": "nested"
+    }
+  }
+}
+`
+	f, err := parseFormatFile(t, cfg, "app.json", contents)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	assertFileValues(t, f, "", map[string]string{"visible": "top level", "port": "3307", "bool1": "1", "truthybool": "", "ignore-table": "b", "labels": "env=prod"})
+	assertFileValues(t, f, "prod", map[string]string{"hasshort": `say "hi"`, "port": "3308", "bool2": "false"})
+	assertFileValues(t, f, "prod.west", map[string]string{"visible": "nested"})
+	if values, _ := f.AllOptionValues("ignore-table"); !slices.Equal(values, []string{"a", "b"}) {
+		t.Errorf("Unexpected values for repeatable option: %q", values)
+	}
+	if loc, ok := f.OptionLocation("port"); !ok || loc.LineNumber != 3 {
+		t.Errorf("Unexpected location for port: %+v", loc)
+	}
+	f.UseSection("prod")
+	if loc, ok := f.OptionLocation("hasshort"); !ok || loc.LineNumber != 10 || loc.Section != "prod" {
+		t.Errorf("Unexpected location for hasshort: %+v", loc)
+	}
+
+	// Write should refuse to discard the skipped loose option; once it is gone,
+	// the file should round-trip through Write
+	f.SetOptionValue("prod", "visible", "<new>")
+	if err := f.Write(true); err == nil || !strings.Contains(err.Error(), "loose-doesnt-exist") {
+		t.Errorf("Expected error from Write mentioning skipped option, instead found %v", err)
+	}
+	f, err = parseFormatFile(t, cfg, "app.json", strings.Replace(contents, "  \"loose-doesnt-exist\": \"ignored\",\n", "", 1))
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	f.SetOptionValue("prod", "visible", "<new>")
+	if err := f.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	if !strings.Contains(f.contents, `"ignore-table": ["a", "b"]`) || !strings.Contains(f.contents, `"bool1": true`) || !strings.Contains(f.contents, `"visible": "<new>"`) {
+		t.Errorf("Unexpected contents after Write:\n%s", f.contents)
+	}
+	f2 := NewFile(f.Dir, f.Name)
+	if err := f2.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse of rewritten file: %v\n%s", err, f.contents)
+	}
+	assertFileValues(t, f2, "", map[string]string{"visible": "top level", "port": "3307", "bool1": "true", "truthybool": "false", "ignore-table": "b", "labels": "env=prod"})
+	assertFileValues(t, f2, "prod", map[string]string{"hasshort": `say "hi"`, "port": "3308", "bool2": "false", "visible": "<new>"})
+	assertFileValues(t, f2, "prod.west", map[string]string{"visible": "nested"})
+
+	// Errors
+	cases := map[string]string{
+		`{"doesnt-exist": 1}`:             `Unknown option "doesnt-exist"`,
+		`{"visible": null}`:               "Missing required value for option visible",
+		`["visible"]`:                     "line 1: Top-level value must be an object",
+		"{\n\"visible\": \"a\",\n}":       "line 2: invalid character",
+		"{\n\"visible\": [{}]\n}":         "line 2: Value for visible must be",
+		"{\n\"visible\": \"a\"\n}\n[]":    "line 4: Unexpected data",
+		"{\n\"visible\": \"a\"\n":         "line 3: unexpected end of JSON input",
+		"":                                "line 1: Unexpected end of file",
+		`{"port": "abc", "bool1": "yes"}`: "",
+	}
+	for contents, expected := range cases {
+		_, err := parseFormatFile(t, cfg, "app.json", contents)
+		if expected == "" {
+			if err != nil {
+				t.Errorf("Unexpected error parsing %q: %v", contents, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error parsing %q to contain %q, instead found %v", contents, expected, err)
+		}
+	}
+	var fpfErr FileParseFormatError
+	if _, err := parseFormatFile(t, cfg, "app.json", "{"); !errors.As(err, &fpfErr) || !strings.HasSuffix(fpfErr.FilePath, "app.json") {
+		t.Errorf("Expected FileParseFormatError with file path, instead found %v", err)
+	}
+}
+
+func TestTOMLFormat(t *testing.T) {
+	cfg := ParseFakeCLI(t, formatTestCommand(), "mycommand arg1")
+	contents := `# Comment
+visible = "top \"level\"" # trailing comment
+port = 3307
+bool1 = true
+truthybool = false
+loose-doesnt-exist = 'ignored'
+ignore-table = ["a", 'b\c']
+labels.env = "prod"
+"hasshort" = "\u00e9\tx"
+
+[prod]
+port = 3308
+
+[ "prod" . west ]
+visible = 'nested'
+`
+	f, err := parseFormatFile(t, cfg, "app.toml", contents)
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	assertFileValues(t, f, "", map[string]string{"visible": `top "level"`, "port": "3307", "bool1": "true", "truthybool": "false", "ignore-table": `b\c`, "labels": "env=prod", "hasshort": "é\tx"})
+	assertFileValues(t, f, "prod", map[string]string{"port": "3308"})
+	assertFileValues(t, f, "prod.west", map[string]string{"visible": "nested"})
+	if loc, ok := f.OptionLocation("labels"); !ok || loc.LineNumber != 8 || loc.RawKey != "labels.env" {
+		t.Errorf("Unexpected location for labels: %+v", loc)
+	}
+
+	// Write should refuse to discard the skipped loose option; once it is gone,
+	// the file should round-trip through Write, other than its comments
+	f.SetOptionValue("new section", "visible", "x")
+	if err := f.Write(true); err == nil || !strings.Contains(err.Error(), "loose-doesnt-exist") {
+		t.Errorf("Expected error from Write mentioning skipped option, instead found %v", err)
+	}
+	f, err = parseFormatFile(t, cfg, "app.toml", strings.Replace(contents, "loose-doesnt-exist = 'ignored'\n", "", 1))
+	if err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	f.SetOptionValue("new section", "visible", "x")
+	if err := f.Write(true); err != nil {
+		t.Fatalf("Unexpected error from Write: %v", err)
+	}
+	if !strings.Contains(f.contents, "ignore-table = [\"a\", \"b\\\\c\"]\n") || !strings.Contains(f.contents, "\n[prod.west]\n") || !strings.Contains(f.contents, "\n[\"new section\"]\nvisible = \"x\"\n") {
+		t.Errorf("Unexpected contents after Write:\n%s", f.contents)
+	}
+	f2 := NewFile(f.Dir, f.Name)
+	if err := f2.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse of rewritten file: %v\n%s", err, f.contents)
+	}
+	if !f.SameContents(f2) {
+		t.Errorf("Rewritten file does not have same contents:\n%s", f.contents)
+	}
+
+	// Errors
+	cases := map[string]string{
+		"doesnt-exist = 1":              `line 1: Unknown option "doesnt-exist"`,
+		"\nvisible":                     "line 2: Expected '='",
+		"visible = \"abc":               "String has no terminating quote",
+		"visible = \"\\q\"":             "Invalid escape sequence",
+		"visible = \"\"\"abc\"\"\"":     "Multi-line strings are not supported",
+		"visible = {a = 1}":             "Inline tables are not supported",
+		"visible = [[1]]":               "Nested arrays",
+		"[[prod]]":                      "Arrays of tables are not supported",
+		"[prod":                         "Expected ']'",
+		"visible = a b":                 "Unexpected text",
+		"visible = [1, 2":               "Expected ',' or ']'",
+		"= 1":                           "Missing key",
+		"visible =":                     "Missing value",
+		"visible = \"\\u12\"":           "Invalid unicode escape sequence",
+		"port = 1_000\nvisible = 1e3\n": "",
+	}
+	for contents, expected := range cases {
+		_, err := parseFormatFile(t, cfg, "app.toml", contents)
+		if expected == "" {
+			if err != nil {
+				t.Errorf("Unexpected error parsing %q: %v", contents, err)
+			}
+		} else if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("Expected error parsing %q to contain %q, instead found %v", contents, expected, err)
+		}
+	}
+}
+
+func TestFileFormatSelection(t *testing.T) {
+	cfg := ParseFakeCLI(t, formatTestCommand(), "mycommand arg1")
+	for name, expected := range map[string]FileFormat{"a.cnf": nil, "a.JSON": JSONFormat{}, "a.toml": TOMLFormat{}, "toml": nil} {
+		if actual := NewFile("/tmp", name).format(); actual != expected {
+			t.Errorf("Unexpected format for %s: expected %T, found %T", name, expected, actual)
+		}
+	}
+
+	// Format field overrides extension, and is preserved by reloads
+	f := NewFile(t.TempDir(), "app.cnf")
+	f.Format = JSONFormat{}
+	if err := os.WriteFile(f.Path(), []byte(`{"port": 1234}`), 0666); err != nil {
+		t.Fatalf("Unable to write file: %v", err)
+	}
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	nf, err := f.reloaded(cfg)
+	if err != nil || nf.Format != f.Format {
+		t.Fatalf("Unexpected return from reloaded: %+v, %v", nf, err)
+	}
+	cfg.AddSource(nf)
+	if value, _ := cfg.GetInt("port"); value != 1234 {
+		t.Errorf("Unexpected value for port: %d", value)
+	}
+}
+
+// parseFormatFile writes contents to a temporary file with the supplied name,
+// and then returns the result of parsing it.
+func parseFormatFile(t *testing.T, cfg *Config, name, contents string) (*File, error) {
+	t.Helper()
+	f := NewFile(filepath.Join(t.TempDir(), name))
+	if err := os.WriteFile(f.Path(), []byte(contents), 0666); err != nil {
+		t.Fatalf("Unable to write file: %v", err)
+	}
+	return f, f.Parse(cfg)
+}
+
+func assertFileValues(t *testing.T, f *File, section string, expected map[string]string) {
+	t.Helper()
+	actual := f.SectionValues(section)
+	for name, value := range expected {
+		if actual[name] != value {
+			t.Errorf("Unexpected value for %s in section %q: expected %q, found %q", name, section, value, actual[name])
+		}
+	}
+	if len(actual) != len(expected) {
+		t.Errorf("Expected section %q to have %d values, instead found %d: %v", section, len(expected), len(actual), actual)
+	}
+}
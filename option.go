@@ -1,11 +1,16 @@
 package mybase
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"regexp"
 	"runtime"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 	"unicode"
 
 	"github.com/mitchellh/go-wordwrap"
@@ -25,6 +30,52 @@ const (
 	OptionTypeBool                     // Boolean-valued option
 )
 
+// ValueType is an enum for representing the semantic type of an option's
+// value. From the perspective of the CLI or an option file, all non-boolean
+// option values are strings, but options created with a typed constructor such
+// as IntOption or EnumOption record their ValueType. This is used to generate
+// type-specific usage text, and to check values in Config.Validate.
+type ValueType int
+
+// Constants representing different ValueType enumerated values.
+const (
+	ValueTypeAny      ValueType = iota // No particular semantic type; any value is permitted
+	ValueTypeInt                       // Integer, as per Config.GetInt
+	ValueTypeFloat                     // Floating-point number
+	ValueTypeBytes                     // Number of bytes, as per Config.GetBytes
+	ValueTypeEnum                      // One of a fixed set of allowed values, as per Config.GetEnum
+	ValueTypeDuration                  // Duration, as per time.ParseDuration
+	ValueTypeRegexp                    // Regular expression, as per Config.GetRegexp
+	ValueTypePath                      // Filesystem path, as per Config.GetAbsPath
+	ValueTypeCount                     // Number of times a boolean option was supplied, as per Config.GetCount
+	ValueTypeMap                       // Comma-separated key=value pairs, as per Config.GetMap
+)
+
+// placeholder returns the term used to represent a value of this type in usage
+// text, or an empty string for ValueTypeAny.
+func (vt ValueType) placeholder() string {
+	switch vt {
+	case ValueTypeInt:
+		return "int"
+	case ValueTypeFloat:
+		return "float"
+	case ValueTypeBytes:
+		return "size"
+	case ValueTypeEnum:
+		return "value"
+	case ValueTypeDuration:
+		return "duration"
+	case ValueTypeRegexp:
+		return "regexp"
+	case ValueTypePath:
+		return "path"
+	case ValueTypeMap:
+		return "key=value,..."
+	default:
+		return ""
+	}
+}
+
 // Option represents a flag/setting for a Command. Any Option present for a
 // parent Command will automatically be available to all of its descendent
 // subcommands, although subcommands may choose to override the exact semantics
@@ -37,8 +88,15 @@ type Option struct {
 	Description        string
 	RequireValue       bool
 	HiddenOnCLI        bool
-	Group              string // Used in help information
+	Group              string    // Used in help information
+	ValueType          ValueType // Semantic type of value; only set by typed constructors such as IntOption
 	deprecationDetails string
+	repeatable         bool
+	completer          CompletionFunc
+	enumValues         []string
+	validators         []func(string) error
+	ingestValidators   []func(string) error
+	normalizers        []func(string) string
 }
 
 // StringOption creates a string-type Option. By default, string options require
@@ -73,6 +131,180 @@ func BoolOption(long string, short rune, defaultValue bool, description string)
 	}
 }
 
+// IntOption creates a string-type Option whose value must be an integer. By
+// default, the option requires a value, though this can be overridden via
+// ValueOptional().
+func IntOption(long string, short rune, defaultValue int, description string) *Option {
+	return typedOption(long, short, strconv.Itoa(defaultValue), description, ValueTypeInt)
+}
+
+// FloatOption creates a string-type Option whose value must be a floating-point
+// number. By default, the option requires a value, though this can be
+// overridden via ValueOptional().
+func FloatOption(long string, short rune, defaultValue float64, description string) *Option {
+	return typedOption(long, short, strconv.FormatFloat(defaultValue, 'f', -1, 64), description, ValueTypeFloat)
+}
+
+// BytesOption creates a string-type Option whose value must be a number of
+// bytes, optionally with a suffix of K, M, or G, as per Config.GetBytes. By
+// default, the option requires a value, though this can be overridden via
+// ValueOptional().
+func BytesOption(long string, short rune, defaultValue string, description string) *Option {
+	return typedOption(long, short, defaultValue, description, ValueTypeBytes)
+}
+
+// EnumOption creates a string-type Option whose value must be either one of the
+// supplied allowed values, or the default value. Matching is case-insensitive,
+// as per Config.GetEnum. The allowed values are listed in the option's usage
+// text, and are used for tab completion of the option's value. By default, the
+// option requires a value, though this can be overridden via ValueOptional().
+// Panics if no allowed values are supplied.
+func EnumOption(long string, short rune, defaultValue string, description string, allowedValues ...string) *Option {
+	if len(allowedValues) == 0 {
+		panic(fmt.Errorf("Option %s: enum options must have at least one allowed value", long))
+	}
+	opt := typedOption(long, short, defaultValue, description, ValueTypeEnum)
+	opt.enumValues = allowedValues
+	opt.completer = func(string, *Config) []string {
+		return opt.AllowedValues()
+	}
+	return opt
+}
+
+// DurationOption creates a string-type Option whose value must be a duration
+// string, such as "300ms" or "1h30m", as per time.ParseDuration. By default,
+// the option requires a value, though this can be overridden via
+// ValueOptional().
+func DurationOption(long string, short rune, defaultValue time.Duration, description string) *Option {
+	var defaultAsStr string
+	if defaultValue != 0 {
+		defaultAsStr = defaultValue.String()
+	}
+	return typedOption(long, short, defaultAsStr, description, ValueTypeDuration)
+}
+
+// RegexpOption creates a string-type Option whose value must be a valid regular
+// expression, as per Config.GetRegexp. By default, the option requires a value,
+// though this can be overridden via ValueOptional().
+func RegexpOption(long string, short rune, defaultValue string, description string) *Option {
+	return typedOption(long, short, defaultValue, description, ValueTypeRegexp)
+}
+
+// PathOption creates a string-type Option whose value is a filesystem path, as
+// per Config.GetAbsPath. By default, the option requires a value, though this
+// can be overridden via ValueOptional().
+func PathOption(long string, short rune, defaultValue string, description string) *Option {
+	return typedOption(long, short, defaultValue, description, ValueTypePath)
+}
+
+// CountOption creates a boolean-type Option which counts the number of times it
+// is supplied on the command-line, as per Config.GetCount. For example, if the
+// option has a shorthand of 'v', "-vvv" and "-v -v -v" both result in a count
+// of 3, as does "--verbose --verbose --verbose". Negating the option, as in
+// "--skip-verbose", resets the count to zero. An explicit count may also be
+// supplied as a value, for example "--verbose=2" on the command-line or
+// "verbose=2" in an option file. Any other value is interpreted as a boolean,
+// with true meaning a count of 1.
+func CountOption(long string, short rune, defaultValue int, description string) *Option {
+	opt := BoolOption(long, short, false, description)
+	if defaultValue != 0 {
+		opt.Default = strconv.Itoa(defaultValue)
+	}
+	opt.ValueType = ValueTypeCount
+	return opt
+}
+
+// MapOption creates a string-type Option whose value is a comma-separated list
+// of key=value pairs, which may be obtained as a map using Config.GetMap with
+// delimiters ',' and '='. In option files, the option may also be set one key
+// at a time using dotted syntax: "name.key=value". All such lines within a
+// section are folded into the option's value, added to any value set by an
+// earlier "name=..." line in the same section.
+func MapOption(long string, short rune, defaultValue string, description string) *Option {
+	return typedOption(long, short, defaultValue, description, ValueTypeMap)
+}
+
+func typedOption(long string, short rune, defaultValue string, description string, valueType ValueType) *Option {
+	opt := StringOption(long, short, defaultValue, description)
+	opt.ValueType = valueType
+	return opt
+}
+
+// AllowedValues returns the allowed values of an Option created by EnumOption,
+// or nil for any other Option.
+func (opt *Option) AllowedValues() []string {
+	return slices.Clone(opt.enumValues)
+}
+
+// Validate adds a validation callback to an Option. The callback is supplied
+// the option's unquoted value, and should return a non-nil error if the value
+// is invalid. Validation callbacks are run by Config.Validate, which is called
+// automatically by Config.HandleCommand prior to running the command's handler.
+// This method may be called multiple times to add multiple callbacks, which are
+// run in order until one returns an error.
+func (opt *Option) Validate(fn func(value string) error) *Option {
+	opt.validators = append(opt.validators, fn)
+	return opt
+}
+
+// WithValidator adds a validation callback to an Option, which is run as soon
+// as a value for the option is parsed from the command-line, an option file,
+// or an EnvSource. This way, invalid values are rejected at parse time, with
+// an OptionValueError describing the source of the value. The callback is
+// supplied the option's unquoted value, after any normalization callbacks have
+// been applied. In contrast to Validate, which defers validation until
+// Config.Validate is called, this means parsing stops at the first invalid
+// value. Callbacks added via WithValidator are also run by Config.Validate, in
+// order to cover values from any other source.
+func (opt *Option) WithValidator(fn func(value string) error) *Option {
+	opt.ingestValidators = append(opt.ingestValidators, fn)
+	return opt
+}
+
+// WithNormalizer adds a callback to an Option, which canonicalizes values for
+// the option as soon as they are parsed from the command-line, an option file,
+// or an EnvSource. For example, a normalizer could lowercase an enum value, or
+// strip trailing slashes from a path. The callback is supplied the option's
+// unquoted value; if the value was quote-wrapped, the callback's return value
+// is wrapped in the same quotes. This method may be called multiple times to
+// add multiple callbacks, which are applied in order.
+func (opt *Option) WithNormalizer(fn func(value string) string) *Option {
+	opt.normalizers = append(opt.normalizers, fn)
+	return opt
+}
+
+// ingestValue applies any normalization and parse-time validation callbacks to
+// the supplied raw value, which is being parsed from source. The returned value
+// should be stored instead of the raw value. location describes where the value
+// was found, for use in errors; if empty, the source's description is used.
+func (opt *Option) ingestValue(raw string, source OptionValuer, location string) (string, error) {
+	if len(opt.normalizers) == 0 && len(opt.ingestValidators) == 0 {
+		return raw, nil
+	}
+	value, quote := trimQuotes(raw)
+	for _, normalizer := range opt.normalizers {
+		value = normalizer(value)
+	}
+	for _, validator := range opt.ingestValidators {
+		if err := validator(value); err != nil {
+			return "", OptionValueError{
+				Name:     opt.Name,
+				Value:    value,
+				Source:   source,
+				Err:      err,
+				location: location,
+			}
+		}
+	}
+	if len(opt.normalizers) == 0 {
+		return raw, nil
+	} else if quote != 0 {
+		escaper := strings.NewReplacer(`\`, `\\`, string(quote), `\`+string(quote))
+		value = string(quote) + escaper.Replace(value) + string(quote)
+	}
+	return value, nil
+}
+
 // Hidden prevents an Option from being displayed in a Command's help/usage
 // text.
 func (opt *Option) Hidden() *Option {
@@ -97,6 +329,21 @@ func (opt *Option) ValueOptional() *Option {
 	return opt
 }
 
+// Repeatable marks an Option as accumulating values, rather than each value
+// overriding any previous one. Every occurrence of the option on the
+// command-line, or within a section of an option file, is retained, and
+// Config.GetAll returns the values from all sources combined. The option
+// still has a single value for purposes of Config.Get and other getters: its
+// last value from the highest-priority source. Boolean options cannot be
+// repeatable; see CountOption instead.
+func (opt *Option) Repeatable() *Option {
+	if opt.Type == OptionTypeBool {
+		panic(fmt.Errorf("Option %s: boolean options cannot be repeatable", opt.Name))
+	}
+	opt.repeatable = true
+	return opt
+}
+
 // MarkDeprecated sets an Option as being deprecated, optionally with the
 // supplied details text.
 func (opt *Option) MarkDeprecated(details string) *Option {
@@ -107,6 +354,20 @@ func (opt *Option) MarkDeprecated(details string) *Option {
 	return opt
 }
 
+// CompleteWith sets a callback for dynamically determining tab completion
+// candidates for the Option's value. This is used by the completion scripts
+// returned by Command.CompletionScript, which invoke the program with a hidden
+// "__complete" first arg in order to obtain candidates. In this situation,
+// ParseCLI parses the rest of the command-line leniently, and the candidates
+// are printed by Config.HandleCommand instead of running the command's
+// handler. This means callbacks may make use of any other option sources (such
+// as option files) which the program adds to the Config prior to calling
+// HandleCommand.
+func (opt *Option) CompleteWith(fn CompletionFunc) *Option {
+	opt.completer = fn
+	return opt
+}
+
 // Usage displays one-line help information on the Option.
 func (opt *Option) Usage(maxNameLength int) string {
 	if opt.HiddenOnCLI {
@@ -131,7 +392,7 @@ func (opt *Option) Usage(maxNameLength int) string {
 		shorthand = fmt.Sprintf("-%c,", opt.Shorthand)
 	}
 	head := fmt.Sprintf("  %3s --%*s  ", shorthand, -1*maxNameLength, opt.usageName())
-	desc := fmt.Sprintf("%s%s", opt.Description, opt.DefaultUsage())
+	desc := fmt.Sprintf("%s%s%s%s", opt.Description, opt.allowedValuesUsage(), opt.repeatableUsage(), opt.DefaultUsage())
 	if len(desc)+len(head) > lineLen {
 		desc = wordwrap.WrapString(desc, uint(lineLen-len(head)))
 		spacer := fmt.Sprintf("\n%s", strings.Repeat(" ", len(head)))
@@ -145,12 +406,33 @@ func (opt *Option) Usage(maxNameLength int) string {
 func (opt *Option) DefaultUsage() string {
 	if opt.HiddenOnCLI || !opt.HasNonzeroDefault() {
 		return ""
+	} else if opt.ValueType == ValueTypeCount {
+		return fmt.Sprintf(" (default %s)", opt.Default)
 	} else if opt.Type == OptionTypeBool {
 		return fmt.Sprintf(" (enabled by default; disable with --skip-%s)", opt.Name)
 	}
 	return fmt.Sprintf(" (default %s)", opt.PrintableDefault())
 }
 
+// allowedValuesUsage returns usage information listing the allowed values of an
+// Option created by EnumOption, or an empty string for any other Option.
+func (opt *Option) allowedValuesUsage() string {
+	if len(opt.enumValues) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" (allowed values: %s)", quotedList(opt.enumValues))
+}
+
+// repeatableUsage returns usage information noting that an Option created with
+// Repeatable may be supplied multiple times, or an empty string for any other
+// Option.
+func (opt *Option) repeatableUsage() string {
+	if !opt.repeatable {
+		return ""
+	}
+	return " (may be repeated)"
+}
+
 // usageName returns the option's name, potentially modified/annotated for
 // display on help screen.
 func (opt *Option) usageName() string {
@@ -161,6 +443,11 @@ func (opt *Option) usageName() string {
 			return fmt.Sprintf("[skip-]%s", opt.Name)
 		}
 		return opt.Name
+	} else if placeholder := opt.ValueType.placeholder(); placeholder != "" {
+		if opt.RequireValue {
+			return fmt.Sprintf("%s=<%s>", opt.Name, placeholder)
+		}
+		return fmt.Sprintf("%s[=<%s>]", opt.Name, placeholder)
 	} else if opt.RequireValue {
 		return fmt.Sprintf("%s value", opt.Name)
 	}
@@ -183,6 +470,10 @@ func (opt *Option) HasNonzeroDefault() bool {
 // PrintableDefault returns a human-friendly version of the Option's default
 // value.
 func (opt *Option) PrintableDefault() string {
+	if opt.ValueType == ValueTypeCount {
+		n, _ := countValue(opt.Default)
+		return strconv.Itoa(n)
+	}
 	switch opt.Type {
 	case OptionTypeBool:
 		if BoolValue(opt.Default) {
@@ -201,6 +492,111 @@ func (opt *Option) Deprecated() bool {
 	return opt.deprecationDetails != ""
 }
 
+// checkValue returns an error if the supplied value, which should already be
+// unquoted, is not valid for opt's ValueType. Blank values are permitted for
+// every ValueType, since an option marked with ValueOptional may be supplied
+// without any value; callers can check for this case prior to calling a typed
+// getter.
+func (opt *Option) checkValue(value string) (err error) {
+	if value == "" {
+		return nil
+	}
+	switch opt.ValueType {
+	case ValueTypeInt:
+		_, err = strconv.Atoi(value)
+	case ValueTypeFloat:
+		_, err = parseFloat(value)
+	case ValueTypeBytes:
+		_, err = parseBytes(value)
+	case ValueTypeEnum:
+		if _, ok := enumValue(value, opt.Default, opt.enumValues); !ok {
+			err = errors.New("not an allowed value")
+		}
+	case ValueTypeDuration:
+		_, err = parseDuration(value)
+	case ValueTypeRegexp:
+		_, err = regexp.Compile(value)
+	case ValueTypeMap:
+		_, err = parseMap(value, ',', '=')
+	case ValueTypeCount:
+		if _, ok := countValue(value); !ok {
+			err = errors.New("negative count")
+		}
+	}
+	return err
+}
+
+// countValue parses the value of an option created by CountOption. A
+// non-negative integer is an explicit count, whereas any other value is
+// interpreted as a boolean as per BoolValue, representing a count of 1 or 0.
+// The boolean return value is false if the value is a negative integer.
+func countValue(value string) (int, bool) {
+	if n, err := strconv.Atoi(value); err == nil {
+		return n, n >= 0
+	} else if BoolValue(value) {
+		return 1, true
+	}
+	return 0, true
+}
+
+// expectedValue returns a description of valid values for opt's ValueType, for
+// use in error messages.
+func (opt *Option) expectedValue() string {
+	if opt.ValueType == ValueTypeEnum {
+		return expectedEnumValue(opt.Default, opt.enumValues)
+	}
+	return expectedValueOfType(opt.ValueType)
+}
+
+// expectedValueOfType returns a description of valid values for the supplied
+// ValueType, for use in error messages. ValueTypeEnum is not handled, since it
+// requires a list of allowed values; see expectedEnumValue.
+func expectedValueOfType(valueType ValueType) string {
+	switch valueType {
+	case ValueTypeInt:
+		return "integer"
+	case ValueTypeFloat:
+		return "number"
+	case ValueTypeBytes:
+		return "byte size such as 64K, 128M, or 2G"
+	case ValueTypeDuration:
+		return "duration such as 30s or 1h15m, or a number of seconds"
+	case ValueTypeRegexp:
+		return "regular expression"
+	case ValueTypeCount:
+		return "non-negative integer count or boolean"
+	case ValueTypeMap:
+		return expectedMapValue(',', '=')
+	default:
+		return ""
+	}
+}
+
+// expectedMapValue returns a description of valid values for a map using the
+// supplied delimiters, for use in error messages.
+func expectedMapValue(pairDelim, kvDelim rune) string {
+	return fmt.Sprintf("key%cvalue pairs separated by %q", kvDelim, string(pairDelim))
+}
+
+// expectedEnumValue returns a description of valid values for an enum, for
+// use in error messages. The default value is always considered valid.
+func expectedEnumValue(defaultValue string, allowedValues []string) string {
+	if !slices.ContainsFunc(allowedValues, func(v string) bool { return strings.EqualFold(v, defaultValue) }) {
+		allowedValues = append(slices.Clip(allowedValues), defaultValue)
+	}
+	return "one of " + quotedList(allowedValues)
+}
+
+// quotedList returns values as a comma-separated list, with each value wrapped
+// in double quotes.
+func quotedList(values []string) string {
+	quoted := make([]string, len(values))
+	for n := range values {
+		quoted[n] = fmt.Sprintf(`"%s"`, values[n])
+	}
+	return strings.Join(quoted, ", ")
+}
+
 // OptionGroup is a group of related Options, used in generation of usage
 // instructions for a Command.
 type OptionGroup struct {
@@ -324,3 +720,37 @@ func (omv OptionMissingValueError) Error() string {
 	}
 	return fmt.Sprintf("%sMissing required value for option %s", source, omv.Name)
 }
+
+// OptionValueError is an error returned when an option's value is not valid.
+// It is returned by Config.Validate, as well as by typed getters such as
+// Config.GetInt.
+type OptionValueError struct {
+	Name     string       // Name of the option
+	Value    string       // Invalid value, after unquoting
+	Source   OptionValuer // Source which supplied the value
+	Expected string       // Description of a valid value, e.g. "integer"
+	Err      error        // Underlying error, if any
+	location string       // Description of where the value was supplied; derived from Source if empty
+}
+
+// Error satisfies golang's error interface.
+func (ove OptionValueError) Error() string {
+	location := ove.location
+	if location == "" && ove.Source != nil {
+		location = describeSource(ove.Source, ove.Name)
+	}
+	prefix := "Option " + ove.Name
+	if location != "" {
+		prefix += " in " + location
+	}
+	if ove.Expected == "" && ove.Err != nil {
+		return fmt.Sprintf("%s: invalid value \"%s\": %s", prefix, ove.Value, ove.Err)
+	}
+	return fmt.Sprintf("%s: expected %s, got \"%s\"", prefix, ove.Expected, ove.Value)
+}
+
+// Unwrap returns the underlying error, if any. This permits use of errors.Is
+// and errors.As with OptionValueError.
+func (ove OptionValueError) Unwrap() error {
+	return ove.Err
+}
@@ -0,0 +1,123 @@
+package mybase
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// DefaultWatchInterval is the polling interval used by Config.Watch.
+const DefaultWatchInterval = 2 * time.Second
+
+// ConfigChange describes the result of a reload performed by Config.Watch.
+type ConfigChange struct {
+	Files   []*File  // Files which were re-read and re-parsed; see Config.Reload
+	Options []string // Names of options whose value or source changed, sorted by name
+	Err     error    // Non-nil if a changed file could not be reloaded; the Config retains its previous values in this case
+}
+
+// Watch polls all *File sources of cfg for changes, at an interval of
+// DefaultWatchInterval. See WatchWithInterval for more information.
+func (cfg *Config) Watch(ctx context.Context) <-chan ConfigChange {
+	return cfg.WatchWithInterval(ctx, DefaultWatchInterval)
+}
+
+// WatchWithInterval starts a goroutine which calls cfg.Reload at the supplied
+// interval, until ctx is done, at which point the returned channel is closed.
+// Changes are detected by checking each file's modification time, size, and
+// contents; no OS-specific filesystem notification mechanism is used.
+// Whenever Reload replaces one or more files, a ConfigChange is sent on the
+// returned channel, listing the names of options whose value or source
+// differs from prior to the reload. This list may be empty, for example if
+// only comments in a file were changed. A reloaded File is not considered to
+// be a different source than the File that it replaced.
+// If Reload returns an error, a ConfigChange with a non-nil Err is sent.
+// Repeated identical errors, such as from a file which remains unparseable
+// for several intervals, are only sent once.
+func (cfg *Config) WatchWithInterval(ctx context.Context, interval time.Duration) <-chan ConfigChange {
+	ch := make(chan ConfigChange)
+	go func() {
+		defer close(ch)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		var prevErr string
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			files, before, after, err := cfg.reload()
+			var change ConfigChange
+			if err != nil {
+				if err.Error() == prevErr {
+					continue
+				}
+				prevErr = err.Error()
+				change.Err = err
+			} else if len(files) == 0 {
+				continue
+			} else {
+				prevErr = ""
+				change.Files = files
+				change.Options = before.changedOptions(after)
+			}
+			select {
+			case ch <- change:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// changedOptions returns a sorted list of option names whose value or source
+// in newer differs from that in cache.
+func (cache *configCache) changedOptions(newer *configCache) []string {
+	var names []string
+	for name := range newer.unifiedValues {
+		value, _ := newer.value(name)
+		oldValue, ok := cache.value(name)
+		// Runtime overrides are replaced rather than modified whenever one is set,
+		// but still represent the same source
+		_, isOverride := newer.overrides[name]
+		_, wasOverride := cache.overrides[name]
+		changedSource := isOverride != wasOverride
+		if !isOverride && !wasOverride {
+			changedSource = !sameSource(cache.unifiedSources[name], newer.unifiedSources[name])
+		}
+		if !ok || oldValue != value || changedSource {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// sameSource returns true if a and b represent the same option source. Two
+// Files are considered to be the same source if they have the same path, since
+// Config.Reload replaces Files with new values. This function is safe to use
+// with sources which are not comparable using ==, such as StringMapValues.
+func sameSource(a, b OptionValuer) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	if fa, ok := a.(*File); ok {
+		fb, ok := b.(*File)
+		return ok && fa.Path() == fb.Path()
+	}
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	if va.Type() != vb.Type() {
+		return false
+	}
+	switch va.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Func:
+		return va.Pointer() == vb.Pointer()
+	}
+	if !va.Comparable() {
+		return false
+	}
+	return a == b
+}
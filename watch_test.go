@@ -0,0 +1,126 @@
+package mybase
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestConfigWatch(t *testing.T) {
+	f := NewFile(t.TempDir(), "watch.cnf")
+	modTime := time.Now().Add(-time.Hour)
+	// Write to a temp file and rename it into place, so that the watcher can't
+	// observe a partially-written file
+	writeFile := func(contents string) {
+		t.Helper()
+		tempPath := f.Path() + ".tmp"
+		if err := os.WriteFile(tempPath, []byte(contents), 0666); err != nil {
+			t.Fatalf("Unable to write %s: %v", tempPath, err)
+		}
+		modTime = modTime.Add(time.Minute)
+		if err := os.Chtimes(tempPath, modTime, modTime); err != nil {
+			t.Fatalf("Unable to set mtime of %s: %v", tempPath, err)
+		}
+		if err := os.Rename(tempPath, f.Path()); err != nil {
+			t.Fatalf("Unable to rename %s: %v", tempPath, err)
+		}
+	}
+	receive := func(ch <-chan ConfigChange) ConfigChange {
+		t.Helper()
+		select {
+		case change := <-ch:
+			return change
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for ConfigChange")
+		}
+		return ConfigChange{}
+	}
+
+	writeFile("visible=one\nhasshort=one\n# comment\n")
+	cfg := ParseFakeCLI(t, simpleCommand(), "mycommand arg1")
+	if err := f.Parse(cfg); err != nil {
+		t.Fatalf("Unexpected error from Parse: %v", err)
+	}
+	cfg.AddSource(f)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := cfg.WatchWithInterval(ctx, 5*time.Millisecond)
+
+	// Changes in value or source should be reported, but unchanged values from
+	// the reloaded file should not be
+	writeFile("visible=two\nhasshort=one\nbool1\n# comment\n")
+	change := receive(ch)
+	if change.Err != nil || len(change.Files) != 1 || !slices.Equal(change.Options, []string{"bool1", "visible"}) {
+		t.Errorf("Unexpected ConfigChange: %+v", change)
+	}
+	if cfg.Get("visible") != "two" {
+		t.Errorf("Unexpected value for visible after reload: %q", cfg.Get("visible"))
+	}
+
+	// A change with no effect on values should still be reported
+	writeFile("visible=two\nhasshort=one\nbool1\n")
+	if change := receive(ch); change.Err != nil || len(change.Files) != 1 || len(change.Options) != 0 {
+		t.Errorf("Unexpected ConfigChange: %+v", change)
+	}
+
+	// Errors should be reported once, and then a subsequent fix should be
+	// reported relative to the last good state
+	writeFile("visible=three\ndoesnt-exist=1\n")
+	if change := receive(ch); change.Err == nil || len(change.Files) != 0 {
+		t.Errorf("Unexpected ConfigChange: %+v", change)
+	}
+	writeFile("visible=three\nhasshort=one\n")
+	if change := receive(ch); change.Err != nil || !slices.Equal(change.Options, []string{"bool1", "visible"}) {
+		t.Errorf("Unexpected ConfigChange: %+v", change)
+	}
+
+	// Channel should be closed once ctx is done
+	cancel()
+	for range ch {
+	}
+
+	// The snapshots used for determining changed options should both reflect
+	// changes made prior to the reload, even if the cache had been cleared
+	cfg.SetRuntimeOverride("hidden", "overridden")
+	cfg.MarkDirty()
+	writeFile("visible=four\nhasshort=one\n")
+	files, before, after, err := cfg.reload()
+	if err != nil || len(files) != 1 {
+		t.Fatalf("Unexpected return from reload: %v, %v", files, err)
+	}
+	if value, _ := before.value("hidden"); value != "overridden" || after != cfg.current() {
+		t.Error("Unexpected snapshots returned by reload")
+	}
+	if options := before.changedOptions(after); !slices.Equal(options, []string{"visible"}) {
+		t.Errorf("Unexpected changed options: %v", options)
+	}
+}
+
+func TestSameSource(t *testing.T) {
+	cmd := simpleCommand()
+	cli := &CommandLine{Command: cmd}
+	smv1, smv2 := StringMapValues{"a": "b"}, StringMapValues{"a": "b"}
+	f1, f2, f3 := NewFile("/tmp/one.cnf"), NewFile("/tmp/one.cnf"), NewFile("/tmp/two.cnf")
+	cases := []struct {
+		a, b     OptionValuer
+		expected bool
+	}{
+		{cmd, cmd, true},
+		{cmd, cli, false},
+		{cli, cli, true},
+		{smv1, smv1, true},
+		{smv1, smv2, false},
+		{smv1, cli, false},
+		{f1, f2, true},
+		{f1, f3, false},
+		{f1, smv1, false},
+		{nil, f1, false},
+	}
+	for _, c := range cases {
+		if actual := sameSource(c.a, c.b); actual != c.expected {
+			t.Errorf("Expected sameSource(%v, %v) to return %t, instead found %t", c.a, c.b, c.expected, actual)
+		}
+	}
+}